@@ -0,0 +1,117 @@
+// Package ansi formats the Git status with ANSI escape sequences, for plain
+// terminals, with the same configuration as the tmux status bar.
+package ansi
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/arl/gitstatus"
+
+	"github.com/arl/gitmux/tmux"
+)
+
+// A Formater formats the Git status as text with ANSI escape sequences. It
+// formats it as the tmux Formater would, and translates tmux styles to SGR
+// escape sequences.
+type Formater struct {
+	tmux.Formater
+}
+
+// Format writes st into w, using ANSI escape sequences for styles.
+func (f *Formater) Format(w io.Writer, st *gitstatus.Status) error {
+	var sb strings.Builder
+	if err := f.Formater.Format(&sb, st); err != nil {
+		return err
+	}
+
+	spans := tmux.Spans(sb.String())
+	if len(spans) == 0 {
+		return nil
+	}
+
+	var out strings.Builder
+	for _, span := range spans {
+		out.WriteString("\x1b[" + SGR(span.Style) + "m")
+		out.WriteString(span.Text)
+	}
+
+	// Don't let styles leak into what's printed next.
+	out.WriteString("\x1b[0m")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// SGR returns the parameters of the SGR (Select Graphic Rendition) escape
+// sequence setting style, starting with a reset. For example "0;1;31" for
+// tmux style "fg=red,bold".
+func SGR(style tmux.Style) string {
+	codes := []string{"0"}
+	codes = append(codes, attrCodes(style.Attrs)...)
+	codes = append(codes, colorCodes(style.Fg, 30, 90, 38)...)
+	codes = append(codes, colorCodes(style.Bg, 40, 100, 48)...)
+	if style.Us.Kind != tmux.ColorDefault {
+		codes = append(codes, colorCodes(style.Us, -1, -1, 58)...)
+	}
+	return strings.Join(codes, ";")
+}
+
+// AttrSGR returns the parameters of the SGR escape sequence turning attrs on,
+// or "" if attrs is 0.
+func AttrSGR(attrs tmux.Attr) string {
+	return strings.Join(attrCodes(attrs), ";")
+}
+
+// sgrAttrs maps style attributes to SGR parameters.
+var sgrAttrs = []struct {
+	attr tmux.Attr
+	code string
+}{
+	{tmux.AttrBold, "1"},
+	{tmux.AttrDim, "2"},
+	{tmux.AttrItalics, "3"},
+	{tmux.AttrUnderscore, "4"},
+	{tmux.AttrDoubleUnderscore, "21"},
+	{tmux.AttrCurlyUnderscore, "4:3"},
+	{tmux.AttrDottedUnderscore, "4:4"},
+	{tmux.AttrDashedUnderscore, "4:5"},
+	{tmux.AttrBlink, "5"},
+	{tmux.AttrReverse, "7"},
+	{tmux.AttrHidden, "8"},
+	{tmux.AttrStrikethrough, "9"},
+	{tmux.AttrOverline, "53"},
+}
+
+func attrCodes(attrs tmux.Attr) []string {
+	var codes []string
+	for _, a := range sgrAttrs {
+		if attrs&a.attr != 0 {
+			codes = append(codes, a.code)
+		}
+	}
+	return codes
+}
+
+// colorCodes returns the SGR parameters setting color c, given the base
+// parameters for basic colors, bright colors and extended colors. Basic and
+// bright colors use extended parameters if their base is negative.
+func colorCodes(c tmux.Color, basic, bright, extended int) []string {
+	switch c.Kind {
+	case tmux.ColorBasic:
+		switch {
+		case c.Value < 8 && basic >= 0:
+			return []string{strconv.Itoa(basic + c.Value)}
+		case c.Value >= 8 && bright >= 0:
+			return []string{strconv.Itoa(bright + c.Value - 8)}
+		}
+		return []string{strconv.Itoa(extended), "5", strconv.Itoa(c.Value)}
+	case tmux.Color256:
+		return []string{strconv.Itoa(extended), "5", strconv.Itoa(c.Value)}
+	case tmux.ColorRGB:
+		r, g, b := c.Value>>16&0xff, c.Value>>8&0xff, c.Value&0xff
+		return []string{strconv.Itoa(extended), "2", strconv.Itoa(r), strconv.Itoa(g), strconv.Itoa(b)}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arl/gitstatus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/arl/gitmux/tmux"
+)
+
+func TestSGR(t *testing.T) {
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{style: "", want: "0"},
+		{style: "fg=red,bold", want: "0;1;31"},
+		{style: "fg=brightgreen bg=black", want: "0;92;40"},
+		{style: "fg=colour208,bg=brightwhite,italics,dim", want: "0;2;3;38;5;208;107"},
+		{style: "fg=#ff8000,bg=#000102", want: "0;38;2;255;128;0;48;2;0;1;2"},
+		{style: "curly-underscore,us=red,strikethrough", want: "0;4:3;9;58;5;1"},
+		{style: "bold,nobold,reverse", want: "0;7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			var style tmux.Style
+			if spans := tmux.Spans("#[" + tt.style + "]x"); len(spans) == 1 {
+				style = spans[0].Style
+			}
+			if got := SGR(style); got != tt.want {
+				t.Errorf("SGR(%q) = %q, want %q", tt.style, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	const cfgYAML = `
+symbols:
+    branch: "⎇ "
+    modified: "✚ "
+styles:
+    clear: "#[none]"
+    branch: "#[fg=white,bold]"
+    modified: "#[fg=red,bold]"
+layout: [branch, " - ", flags]
+`
+	var cfg tmux.Config
+	if err := yaml.Unmarshal([]byte(cfgYAML), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		st   *gitstatus.Status
+		want string
+	}{
+		{
+			name: "clean",
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "main"},
+				IsClean:   true,
+			},
+			want: "\x1b[0;1;37m⎇ main\x1b[0;37m - \x1b[0m",
+		},
+		{
+			name: "modified",
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "main", NumModified: 2},
+			},
+			want: "\x1b[0;1;37m⎇ main\x1b[0;37m - \x1b[0;1;31m✚ 2\x1b[0m",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{Formater: tmux.Formater{Config: cfg}}
+			var sb strings.Builder
+			if err := f.Format(&sb, tt.st); err != nil {
+				t.Fatal(err)
+			}
+			if got := sb.String(); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arl/gitmux/tmux"
+)
+
+// strictConfig mirrors Config, with profiles decoded as configurations so that
+// they get checked too.
+type strictConfig struct {
+	Include  []string                `yaml:"include"`
+	Tmux     tmux.Config             `yaml:"tmux"`
+	Profiles map[string]strictConfig `yaml:"profiles"`
+}
+
+// A cfgProblem is a problem found in a configuration file.
+type cfgProblem struct {
+	line, column int
+	msg          string
+}
+
+// checkConfig checks the configuration file at path. It returns the problems
+// found: unknown keys, invalid values, unknown layout keywords and invalid
+// style strings.
+func checkConfig(path string) ([]string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return checkConfigData(path, buf), nil
+}
+
+// checkConfigData checks buf, the content of the configuration file at path.
+func checkConfigData(path string, buf []byte) []string {
+	var root yaml.Node
+	if err := yaml.Unmarshal(buf, &root); err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+
+	var problems []cfgProblem
+
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+	if err := dec.Decode(&strictConfig{}); err != nil {
+		var terr *yaml.TypeError
+		msgs := []string{err.Error()}
+		if errors.As(err, &terr) {
+			msgs = terr.Errors
+		}
+		for _, msg := range msgs {
+			problems = append(problems, locate(&root, msg))
+		}
+	}
+
+	for _, node := range tmuxNodes(&root) {
+		for _, err := range tmux.Check(node) {
+			var cerr *tmux.CheckError
+			if errors.As(err, &cerr) {
+				problems = append(problems, cfgProblem{line: cerr.Line, column: cerr.Column, msg: cerr.Msg})
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].line != problems[j].line {
+			return problems[i].line < problems[j].line
+		}
+		return problems[i].column < problems[j].column
+	})
+
+	var out []string
+	for _, p := range problems {
+		if p.line == 0 {
+			out = append(out, fmt.Sprintf("%s: %s", path, p.msg))
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s:%d:%d: %s", path, p.line, p.column, p.msg))
+	}
+	return out
+}
+
+// tmuxNodes returns the nodes of the tmux sections of the configuration, the
+// top-level one and the ones of the profiles.
+func tmuxNodes(root *yaml.Node) []*yaml.Node {
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	var nodes []*yaml.Node
+	doc := root.Content[0]
+	if n := tmux.MappingValue(doc, "tmux"); n != nil {
+		nodes = append(nodes, n)
+	}
+	if profiles := tmux.MappingValue(doc, "profiles"); profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 1; i < len(profiles.Content); i += 2 {
+			if n := tmux.MappingValue(profiles.Content[i], "tmux"); n != nil {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+var (
+	// errLineRx matches the line number prefixing yaml errors.
+	errLineRx = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+	// unknownFieldRx matches yaml errors about unknown keys.
+	unknownFieldRx = regexp.MustCompile(`^field (\S+) not found in type`)
+)
+
+// locate returns the problem described by a yaml error message, located at
+// the line it mentions and at the column of the node it's about.
+func locate(root *yaml.Node, msg string) cfgProblem {
+	m := errLineRx.FindStringSubmatch(msg)
+	if m == nil {
+		return cfgProblem{msg: msg}
+	}
+
+	line, _ := strconv.Atoi(m[1])
+	p := cfgProblem{line: line, column: 1, msg: m[2]}
+
+	// Unknown keys are located at the key, invalid values at the last node of
+	// the line, which is usually the value.
+	key := ""
+	if m := unknownFieldRx.FindStringSubmatch(p.msg); m != nil {
+		key = m[1]
+		p.msg = fmt.Sprintf("unknown key %q", key)
+	}
+
+	found := false
+	var walk func(*yaml.Node)
+	walk = func(n *yaml.Node) {
+		if found {
+			return
+		}
+		if n.Kind == yaml.ScalarNode && n.Line == line {
+			if key == "" {
+				p.column = n.Column
+			} else if n.Value == key {
+				p.column, found = n.Column, true
+			}
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(root)
+	return p
+}
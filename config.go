@@ -1,8 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 
@@ -10,7 +17,17 @@ import (
 )
 
 // Config configures output formatting.
-type Config struct{ Tmux tmux.Config }
+type Config struct {
+	// Include lists configuration files merged before this one, relative to
+	// its directory.
+	Include []string `yaml:"include"`
+
+	Tmux tmux.Config `yaml:"tmux"`
+
+	// Profiles are named configurations, in the same format, selected with
+	// the -profile flag and merged over the rest of the configuration.
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
 
 // default config (decoded in init)
 var defaultCfg Config
@@ -23,3 +40,300 @@ func init() {
 		panic(fmt.Sprintf("default config is invalid: %v", err))
 	}
 }
+
+// loadConfig returns the configuration read from the file at path, and the
+// files it includes, merged over the default configuration. If profile isn't
+// empty, the profile of that name is then merged over it.
+func loadConfig(path, profile string) (Config, error) {
+	l := cfgLoader{cfg: defaultCfg, loading: make(map[string]bool)}
+	if path != "" {
+		if err := l.load(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if profile != "" {
+		node, ok := l.cfg.Profiles[profile]
+		if !ok {
+			return Config{}, fmt.Errorf("unknown profile %q", profile)
+		}
+		if err := node.Decode(&l.cfg); err != nil {
+			return Config{}, fmt.Errorf("profile %q: %v", profile, err)
+		}
+	}
+	return l.cfg, nil
+}
+
+type cfgLoader struct {
+	cfg     Config
+	loading map[string]bool // loading holds the files being loaded, to detect include cycles.
+}
+
+// load merges the configuration file at path into l.cfg, after the files it
+// includes.
+func (l *cfgLoader) load(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if l.loading[abs] {
+		return fmt.Errorf("%s: include cycle", path)
+	}
+	l.loading[abs] = true
+	defer delete(l.loading, abs)
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var hdr struct {
+		Include []string `yaml:"include"`
+	}
+	if err := yaml.Unmarshal(buf, &hdr); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	for _, inc := range hdr.Include {
+		inc, err := includePath(path, inc)
+		if err != nil {
+			return err
+		}
+		if err := l.load(inc); err != nil {
+			return err
+		}
+	}
+
+	if err := yaml.Unmarshal(buf, &l.cfg); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// includePath returns the path of the file inc included by the configuration
+// file at path. It's either relative to the home directory if it starts with
+// ~/, absolute, or relative to the directory of path.
+func includePath(path, inc string) (string, error) {
+	if rest, ok := strings.CutPrefix(inc, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("%s: include %s: %v", path, inc, err)
+		}
+		return filepath.Join(home, rest), nil
+	}
+	if !filepath.IsAbs(inc) {
+		return filepath.Join(filepath.Dir(path), inc), nil
+	}
+	return inc, nil
+}
+
+// findConfig returns the path of the configuration file to use when none is
+// given with -cfg. That's the first existing file among $GITMUX_CONFIG,
+// $XDG_CONFIG_HOME/gitmux/config.yml and ~/.gitmux.yml, or an empty string if
+// there's none, in which case the default configuration is used.
+func findConfig() (string, error) {
+	if path := os.Getenv("GITMUX_CONFIG"); path != "" {
+		// Explicitly set, so it must exist.
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("GITMUX_CONFIG: %v", err)
+		}
+		return path, nil
+	}
+
+	home, _ := os.UserHomeDir()
+
+	var candidates []string
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "gitmux", "config.yml"))
+	} else if home != "" {
+		candidates = append(candidates, filepath.Join(home, ".config", "gitmux", "config.yml"))
+	}
+	if home != "" {
+		candidates = append(candidates, filepath.Join(home, ".gitmux.yml"))
+	}
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// repoCfgFile is the name of the file, at the top-level directory of a
+// repository, overriding the configuration for that repository.
+const repoCfgFile = ".gitmux.yml"
+
+// A cfgOverride is a configuration document merged over the configuration
+// for a repository.
+type cfgOverride struct {
+	Source string // Source is where the override has been read from.
+	YAML   []byte // YAML is the override document.
+
+	// Untrusted is set if the override comes from a file of the repository,
+	// which may have been cloned from anywhere.
+	Untrusted bool
+}
+
+// mergeRepoConfig merges over cfg the configuration overrides of the
+// repository of the current working directory.
+func mergeRepoConfig(ctx context.Context, cfg *Config) error {
+	overrides, err := repoOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	return mergeOverrides(cfg, overrides)
+}
+
+// repoOverrides returns the configuration overrides of the repository of the
+// current working directory. They're read from the .gitmux.yml file at the
+// top-level directory of the repository, then from the gitmux section of the
+// Git configuration.
+func repoOverrides(ctx context.Context) ([]cfgOverride, error) {
+	var overrides []cfgOverride
+	if path, ok := findRepoConfig(); ok {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, cfgOverride{Source: path, YAML: buf, Untrusted: true})
+	}
+
+	node, err := gitConfigOverrides(ctx)
+	if err != nil || node == nil {
+		return overrides, err
+	}
+	buf, err := yaml.Marshal(node)
+	if err != nil {
+		return nil, fmt.Errorf("git config: %v", err)
+	}
+	return append(overrides, cfgOverride{Source: "git config", YAML: buf}), nil
+}
+
+// mergeOverrides merges overrides over cfg, in order. Untrusted overrides
+// can't enable background fetches, so that cloning a repository doesn't make
+// gitmux run commands on its behalf.
+func mergeOverrides(cfg *Config, overrides []cfgOverride) error {
+	for _, o := range overrides {
+		interval := cfg.Tmux.Options.FetchInterval
+		if err := yaml.Unmarshal(o.YAML, cfg); err != nil {
+			return fmt.Errorf("%s: %v", o.Source, err)
+		}
+		if o.Untrusted {
+			cfg.Tmux.Options.FetchInterval = interval
+		}
+	}
+	return nil
+}
+
+// findRepoConfig looks for the repository configuration file, from the current
+// working directory up to the top-level directory of the repository. The
+// ~/.gitmux.yml file is the user configuration, even if the home directory is
+// in a repository, so it's skipped.
+func findRepoConfig() (string, bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+
+	var userCfg os.FileInfo
+	if home, err := os.UserHomeDir(); err == nil {
+		userCfg, _ = os.Stat(filepath.Join(home, repoCfgFile))
+	}
+
+	for {
+		path := filepath.Join(dir, repoCfgFile)
+		if fi, err := os.Stat(path); err == nil && (userCfg == nil || !os.SameFile(fi, userCfg)) {
+			return path, true
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", false // top-level directory
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// gitConfigOverrides returns a YAML document equivalent to the gitmux section
+// of the Git configuration, or nil if there's none. Keys are of the form
+// gitmux.layout, gitmux.template or gitmux.<section>.<name>, where section is
+// symbols, styles or options. Dashes in names stand for underscores since Git
+// doesn't allow the latter, so that gitmux.options.branch-max-len overrides
+// the branch_max_len option.
+func gitConfigOverrides(ctx context.Context) (*yaml.Node, error) {
+	cmd := exec.CommandContext(ctx, "git", "config", "-z", "--get-regexp", `^gitmux\.`)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, nil // no gitmux section
+		}
+		return nil, fmt.Errorf("exec git config: %v", err)
+	}
+
+	tmuxNode := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+	set := func(m *yaml.Node, key string, val *yaml.Node) {
+		// Multi-valued keys: the last value wins.
+		for i := 0; i < len(m.Content); i += 2 {
+			if m.Content[i].Value == key {
+				m.Content[i+1] = val
+				return
+			}
+		}
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, val)
+	}
+
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		key, val, _ := strings.Cut(string(entry), "\n")
+		key, ok := strings.CutPrefix(key, "gitmux.")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "layout":
+			// Layouts are lists, written as in YAML.
+			doc := &yaml.Node{}
+			if err := yaml.Unmarshal([]byte(val), doc); err != nil || len(doc.Content) == 0 {
+				return nil, fmt.Errorf("git config: gitmux.layout: invalid value %q", val)
+			}
+			set(tmuxNode, key, doc.Content[0])
+			continue
+		case "template":
+			set(tmuxNode, key, &yaml.Node{Kind: yaml.ScalarNode, Value: val})
+			continue
+		}
+
+		dot := strings.LastIndexByte(key, '.')
+		if dot == -1 {
+			return nil, fmt.Errorf("git config: unknown key gitmux.%s", key)
+		}
+
+		section, name := key[:dot], strings.ReplaceAll(key[dot+1:], "-", "_")
+		switch section {
+		case "symbols", "styles", "options":
+		default:
+			return nil, fmt.Errorf("git config: unknown key gitmux.%s", key)
+		}
+
+		m, ok := sections[section]
+		if !ok {
+			m = &yaml.Node{Kind: yaml.MappingNode}
+			sections[section] = m
+			set(tmuxNode, section, m)
+		}
+		set(m, name, &yaml.Node{Kind: yaml.ScalarNode, Value: val})
+	}
+
+	if len(tmuxNode.Content) == 0 {
+		return nil, nil
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	set(root, "tmux", tmuxNode)
+	return root, nil
+}
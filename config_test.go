@@ -4,12 +4,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rogpeppe/go-internal/gotooltest"
 	"github.com/rogpeppe/go-internal/testscript"
+	"gopkg.in/yaml.v3"
 )
 
 var updateGolden = flag.Bool("update", false, "update golden files")
@@ -33,3 +39,342 @@ func TestScripts(t *testing.T) {
 	}
 	testscript.Run(t, params)
 }
+
+func TestGitConfigOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries [][2]string // entries are added with git config --add.
+		want    string
+		wantErr string
+	}{
+		{
+			name: "no gitmux section",
+			want: "",
+		},
+		{
+			name: "dashes stand for underscores",
+			entries: [][2]string{
+				{"gitmux.options.branch-max-len", "20"},
+				{"gitmux.symbols.branch", "@ "},
+			},
+			want: "tmux:\n    options:\n        branch_max_len: 20\n    symbols:\n        branch: '@ '\n",
+		},
+		{
+			name: "last value wins",
+			entries: [][2]string{
+				{"gitmux.styles.clear", "#[fg=red]"},
+				{"gitmux.styles.clear", "#[fg=blue]"},
+			},
+			want: "tmux:\n    styles:\n        clear: '#[fg=blue]'\n",
+		},
+		{
+			name: "layout and template",
+			entries: [][2]string{
+				{"gitmux.layout", `[branch, " ", {if: dirty, then: [flags]}]`},
+				{"gitmux.template", "{{.LocalBranch}}"},
+			},
+			want: "tmux:\n    layout: [branch, \" \", {if: dirty, then: [flags]}]\n    template: '{{.LocalBranch}}'\n",
+		},
+		{
+			name:    "invalid layout",
+			entries: [][2]string{{"gitmux.layout", "[branch"}},
+			wantErr: "gitmux.layout: invalid value",
+		},
+		{
+			name:    "unknown section",
+			entries: [][2]string{{"gitmux.colors.branch", "red"}},
+			wantErr: "unknown key gitmux.colors.branch",
+		},
+		{
+			name:    "unknown key",
+			entries: [][2]string{{"gitmux.branch", "red"}},
+			wantErr: "unknown key gitmux.branch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+			t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+			t.Chdir(testRepo(t))
+			for _, e := range tt.entries {
+				if out, err := exec.Command("git", "config", "--add", e[0], e[1]).CombinedOutput(); err != nil {
+					t.Fatalf("git config %s: %v\n%s", e[0], err, out)
+				}
+			}
+
+			node, err := gitConfigOverrides(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ""
+			if node != nil {
+				buf, err := yaml.Marshal(node)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = string(buf)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeOverrides(t *testing.T) {
+	const override = "tmux:\n    options:\n        branch_max_len: 3\n        fetch_interval: 5m\n"
+
+	tests := []struct {
+		name     string
+		override cfgOverride
+		want     time.Duration
+	}{
+		{
+			name:     "git config",
+			override: cfgOverride{Source: "git config", YAML: []byte(override)},
+			want:     5 * time.Minute,
+		},
+		{
+			name:     "repository file",
+			override: cfgOverride{Source: ".gitmux.yml", YAML: []byte(override), Untrusted: true},
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultCfg
+			if err := mergeOverrides(&cfg, []cfgOverride{tt.override}); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Tmux.Options.BranchMaxLen != 3 {
+				t.Errorf("branch_max_len = %d, want 3", cfg.Tmux.Options.BranchMaxLen)
+			}
+			if got := cfg.Tmux.Options.FetchInterval; got != tt.want {
+				t.Errorf("fetch_interval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	cfg := defaultCfg
+	err := mergeOverrides(&cfg, []cfgOverride{{Source: "some/.gitmux.yml", YAML: []byte("tmux: [")}})
+	if err == nil || !strings.HasPrefix(err.Error(), "some/.gitmux.yml: ") {
+		t.Errorf("err = %v, want an error about some/.gitmux.yml", err)
+	}
+}
+
+func TestFindRepoConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string // files are created in the repository.
+		home  string   // home is the home directory, relative to the repository.
+		wd    string   // wd is the working directory, relative to the repository.
+		want  string   // want is the file found, relative to the repository.
+	}{
+		{
+			name: "none",
+			home: "home",
+			wd:   "sub",
+			want: "",
+		},
+		{
+			name:  "top-level directory",
+			files: []string{".gitmux.yml"},
+			home:  "home",
+			wd:    "sub",
+			want:  ".gitmux.yml",
+		},
+		{
+			name:  "closest directory",
+			files: []string{".gitmux.yml", "sub/.gitmux.yml"},
+			home:  "home",
+			wd:    "sub",
+			want:  "sub/.gitmux.yml",
+		},
+		{
+			name:  "user configuration in a repository",
+			files: []string{".gitmux.yml", "sub/.gitmux.yml"},
+			home:  "sub",
+			wd:    "sub",
+			want:  ".gitmux.yml",
+		},
+		{
+			name:  "home directory is the repository",
+			files: []string{".gitmux.yml"},
+			home:  ".",
+			wd:    "sub",
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testRepo(t)
+			if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("HOME", filepath.Join(dir, tt.home))
+			t.Chdir(filepath.Join(dir, tt.wd))
+
+			path, ok := findRepoConfig()
+			want := ""
+			if tt.want != "" {
+				want = filepath.Join(dir, tt.want)
+			}
+			if path != want || ok != (want != "") {
+				t.Errorf("findRepoConfig() = %q, %t, want %q", path, ok, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string // files are created in the working directory.
+		path    string            // path is relative to the working directory.
+		profile string
+		want    string // want is the branch symbol of the loaded configuration.
+		wantErr string
+	}{
+		{
+			name: "no file",
+			want: defaultCfg.Tmux.Symbols.Branch,
+		},
+		{
+			name:  "file over defaults",
+			files: map[string]string{"cfg.yml": "tmux: {symbols: {branch: A}}"},
+			path:  "cfg.yml",
+			want:  "A",
+		},
+		{
+			name: "file over includes",
+			files: map[string]string{
+				"cfg.yml": "include: [a.yml]\ntmux: {symbols: {branch: cfg}}",
+				"a.yml":   "tmux: {symbols: {branch: A}}",
+			},
+			path: "cfg.yml",
+			want: "cfg",
+		},
+		{
+			name: "includes in order",
+			files: map[string]string{
+				"cfg.yml": "include: [a.yml, b.yml]",
+				"a.yml":   "tmux: {symbols: {branch: A}}",
+				"b.yml":   "tmux: {symbols: {branch: B}}",
+			},
+			path: "cfg.yml",
+			want: "B",
+		},
+		{
+			name: "include relative to the including file",
+			files: map[string]string{
+				"cfg.yml":       "include: [dir/a.yml]",
+				"dir/a.yml":     "include: [sub/b.yml]",
+				"dir/sub/b.yml": "tmux: {symbols: {branch: B}}",
+			},
+			path: "cfg.yml",
+			want: "B",
+		},
+		{
+			name: "include in the home directory",
+			files: map[string]string{
+				"cfg.yml":         "include: [~/common.yml]",
+				"home/common.yml": "tmux: {symbols: {branch: home}}",
+			},
+			path: "cfg.yml",
+			want: "home",
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"cfg.yml":   "include: [dir/a.yml]",
+				"dir/a.yml": "include: [../cfg.yml]",
+			},
+			path:    "cfg.yml",
+			wantErr: "include cycle",
+		},
+		{
+			name: "same file included twice",
+			files: map[string]string{
+				"cfg.yml": "include: [a.yml, b.yml, a.yml]",
+				"a.yml":   "tmux: {symbols: {branch: A}}",
+				"b.yml":   "tmux: {symbols: {branch: B}}",
+			},
+			path: "cfg.yml",
+			want: "A",
+		},
+		{
+			name:    "missing include",
+			files:   map[string]string{"cfg.yml": "include: [missing.yml]"},
+			path:    "cfg.yml",
+			wantErr: "missing.yml",
+		},
+		{
+			name: "profile over file",
+			files: map[string]string{
+				"cfg.yml": "tmux: {symbols: {branch: cfg}}\nprofiles: {work: {tmux: {symbols: {branch: work}}}}",
+			},
+			path:    "cfg.yml",
+			profile: "work",
+			want:    "work",
+		},
+		{
+			name: "profile from an include",
+			files: map[string]string{
+				"cfg.yml": "include: [a.yml]\ntmux: {symbols: {branch: cfg}}",
+				"a.yml":   "profiles: {work: {tmux: {symbols: {branch: work}}}}",
+			},
+			path:    "cfg.yml",
+			profile: "work",
+			want:    "work",
+		},
+		{
+			name:    "unknown profile",
+			files:   map[string]string{"cfg.yml": "profiles: {work: {tmux: {symbols: {branch: work}}}}"},
+			path:    "cfg.yml",
+			profile: "home",
+			wantErr: `unknown profile "home"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("HOME", filepath.Join(dir, "home"))
+			t.Chdir(dir)
+
+			cfg, err := loadConfig(tt.path, tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Tmux.Symbols.Branch; got != tt.want {
+				t.Errorf("branch symbol = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/arl/gitstatus"
+
+	"github.com/arl/gitmux/repo"
+)
+
+const (
+	// cacheTTL is the duration during which the daemon serves a cached Git
+	// status before retrieving it again, when the repository can't be
+	// watched for changes.
+	cacheTTL = time.Second
+
+	// idleTTL is the duration after which a cached Git status that hasn't
+	// been requested is evicted, and its repository not watched anymore.
+	idleTTL = 10 * time.Minute
+)
+
+// statusTimeout is the maximum duration of the retrieval of a Git status by
+// the daemon. It's a variable so tests can shorten it.
+var statusTimeout = 10 * time.Second
+
+// errNoDaemon is returned when no gitmux daemon could be reached.
+var errNoDaemon = errors.New("no gitmux daemon")
+
+// defaultSocket returns the default path of the unix socket the gitmux daemon
+// listens on. It's in a directory only the current user can access.
+func defaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gitmux.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gitmux-%d", os.Getuid()), "gitmux.sock")
+}
+
+// daemonRequest is sent by a gitmux client to the daemon.
+type daemonRequest struct {
+	Dir    string       // Dir is the absolute path of the directory to get the status of.
+	Fields repo.Fields  // Fields selects the repository information to retrieve.
+	Opts   repo.Options // Opts configures the retrieval of repository information.
+}
+
+// daemonResponse is sent back by the daemon to a gitmux client.
+type daemonResponse struct {
+	Status    *gitstatus.Status // Status is the Git status of the requested directory.
+	Info      *repo.Info        // Info holds the requested repository information.
+	Overrides []cfgOverride     // Overrides are the configuration overrides of the repository.
+	Err       string            // Err is not empty if the status couldn't be retrieved.
+}
+
+// daemonStatus asks the gitmux daemon listening on socket for the Git status
+// of the current working directory, along with the repository information cfg
+// requires, after having merged the configuration overrides of the repository
+// into cfg. It returns errNoDaemon if no daemon is listening on socket.
+func daemonStatus(ctx context.Context, socket string, cfg *Config) (*gitstatus.Status, *repo.Info, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := daemonRequest{Dir: dir, Fields: cfg.Tmux.Fields(), Opts: cfg.Tmux.RepoOptions()}
+	resp, err := requestDaemon(ctx, socket, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := mergeOverrides(cfg, resp.Overrides); err != nil {
+		return nil, nil, err
+	}
+
+	// The overrides may require other repository information, or retrieve it
+	// differently.
+	fields, opts := cfg.Tmux.Fields(), cfg.Tmux.RepoOptions()
+	if fields&req.Fields != fields || opts != req.Opts {
+		req.Fields, req.Opts = fields, opts
+		if resp, err = requestDaemon(ctx, socket, req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return resp.Status, resp.Info, nil
+}
+
+// requestDaemon sends req to the gitmux daemon listening on socket and returns
+// its response. It returns errNoDaemon if no daemon is listening on socket.
+func requestDaemon(ctx context.Context, socket string, req daemonRequest) (daemonResponse, error) {
+	// Another user listening on socket could serve anything.
+	if fi, err := os.Lstat(socket); err == nil && !ownedByUser(fi) {
+		return daemonResponse{}, fmt.Errorf("%w: %s is owned by another user", errNoDaemon, socket)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return daemonResponse{}, fmt.Errorf("%w: %v", errNoDaemon, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := gob.NewEncoder(conn).Encode(req); err != nil {
+		return daemonResponse{}, fmt.Errorf("can't send request to daemon: %v", err)
+	}
+
+	var resp daemonResponse
+	if err := gob.NewDecoder(conn).Decode(&resp); err != nil {
+		return daemonResponse{}, fmt.Errorf("can't read response from daemon: %v", err)
+	}
+	if resp.Err != "" {
+		return daemonResponse{}, errors.New(resp.Err)
+	}
+	return resp, nil
+}
+
+// A daemon serves the Git status of directories to gitmux clients, caching
+// them until something changes in their repository.
+type daemon struct {
+	dbg bool
+
+	mu      sync.Mutex // mu protects cache and watches.
+	cache   map[cacheKey]*cacheEntry
+	watches map[string]*repoWatch // watches are keyed by Git directory.
+}
+
+func newDaemon(dbg bool) *daemon {
+	return &daemon{
+		dbg:     dbg,
+		cache:   make(map[cacheKey]*cacheEntry),
+		watches: make(map[string]*repoWatch),
+	}
+}
+
+// close stops watching all repositories.
+func (d *daemon) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, e := range d.cache {
+		e.close()
+		delete(d.cache, k)
+	}
+}
+
+// A cacheKey identifies a cached Git status. Clients using different options
+// for the same directory get their own entries, instead of replacing each
+// other's.
+type cacheKey struct {
+	dir  string
+	opts repo.Options
+}
+
+type cacheEntry struct {
+	// ready is closed once the status has been retrieved. The fields below
+	// can't be read before.
+	ready chan struct{}
+
+	st     *gitstatus.Status
+	info   *repo.Info
+	fields repo.Fields // fields is the repository information in info.
+	err    error
+
+	overrides []cfgOverride // overrides are the configuration overrides of the repository.
+	at        time.Time     // at is the time the status was retrieved.
+	used      time.Time     // used is the last time the status was requested.
+
+	stale atomic.Bool // stale is set when a change has been detected.
+
+	// stop unsubscribes the entry from the changes in its repository, nil if
+	// not subscribed. It must be called with daemon.mu held.
+	stop func()
+}
+
+// fresh reports whether the cached status can be served. Errors are only
+// cached for cacheTTL, so that a failed retrieval, such as a timeout, is
+// retried even if nothing changed.
+func (e *cacheEntry) fresh(now time.Time) bool {
+	if e.stale.Load() || now.Sub(e.used) >= idleTTL {
+		return false
+	}
+	select {
+	case <-e.ready:
+	default:
+		return true // being retrieved
+	}
+	return (e.stop != nil && e.err == nil) || now.Sub(e.at) < cacheTTL
+}
+
+// wait waits for the status of the entry to be retrieved, and returns it.
+func (e *cacheEntry) wait(ctx context.Context) (*gitstatus.Status, *repo.Info, []cfgOverride, error) {
+	select {
+	case <-e.ready:
+		return e.st, e.info, e.overrides, e.err
+	case <-ctx.Done():
+		return nil, nil, nil, ctx.Err()
+	}
+}
+
+// close unsubscribes the entry from the changes in its repository, if it was.
+// It must be called with daemon.mu held.
+func (e *cacheEntry) close() {
+	if e.stop != nil {
+		e.stop()
+		e.stop = nil
+	}
+}
+
+// runDaemon runs the gitmux daemon, listening on socket until it gets
+// interrupted.
+func runDaemon(socket string, dbg bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := listen(socket)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	d := newDaemon(dbg)
+	defer d.close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go d.serve(ctx, conn)
+	}
+}
+
+// listen listens on the unix socket, after having removed it if it's a
+// leftover from a previous daemon. The directory of the socket is created if
+// needed, only accessible by the current user, and must be owned by them.
+func listen(socket string) (net.Listener, error) {
+	dir := filepath.Dir(socket)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !ownedByUser(fi) && fi.Mode()&os.ModeSticky == 0 {
+		// Other users could replace the socket.
+		return nil, fmt.Errorf("%s is owned by another user", dir)
+	}
+
+	if fi, err := os.Lstat(socket); err == nil {
+		if !ownedByUser(fi) {
+			return nil, fmt.Errorf("%s is owned by another user", socket)
+		}
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a gitmux daemon is already listening on %s", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(socket, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func (d *daemon) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	var req daemonRequest
+	if err := gob.NewDecoder(conn).Decode(&req); err != nil {
+		d.logf("can't read request: %v", err)
+		return
+	}
+
+	var resp daemonResponse
+	st, info, overrides, err := d.status(ctx, req.Dir, req.Fields, req.Opts)
+	if err != nil {
+		resp.Err = err.Error()
+	} else {
+		resp.Status, resp.Info, resp.Overrides = st, info, overrides
+	}
+
+	if err := gob.NewEncoder(conn).Encode(resp); err != nil {
+		d.logf("can't send response: %v", err)
+	}
+}
+
+// status returns the Git status of dir, the repository information selected by
+// fields and configured by opts and the configuration overrides of the
+// repository, from the cache if nothing changed since they've been retrieved.
+// Clients requesting a status being retrieved wait for it.
+func (d *daemon) status(ctx context.Context, dir string, fields repo.Fields, opts repo.Options) (*gitstatus.Status, *repo.Info, []cfgOverride, error) {
+	d.mu.Lock()
+
+	now := time.Now()
+	key := cacheKey{dir: dir, opts: opts}
+	e, ok := d.cache[key]
+	if ok && e.fresh(now) && e.fields&fields == fields {
+		e.used = now
+		d.mu.Unlock()
+		return e.wait(ctx)
+	}
+	if ok {
+		// Retrieve the union of what's requested by all clients, instead of
+		// alternating between each of them.
+		fields |= e.fields
+	}
+
+	// Evict entries that can't be served anymore, so that the cache doesn't
+	// grow indefinitely.
+	for k, e := range d.cache {
+		if !e.fresh(now) {
+			e.close()
+			delete(d.cache, k)
+		}
+	}
+
+	// The entry, if still there, is about to be replaced.
+	if e, ok := d.cache[key]; ok {
+		e.close()
+	}
+
+	e = &cacheEntry{fields: fields, at: now, used: now, ready: make(chan struct{})}
+	d.cache[key] = e
+	d.mu.Unlock()
+
+	// Git hanging in a repository mustn't keep its clients waiting forever.
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	// Start watching before retrieving the status, so that changes happening
+	// in the meantime aren't missed.
+	stop, err := d.watch(ctx, dir, e)
+	if err != nil {
+		d.logf("can't watch %s: %v", dir, err)
+	}
+
+	st, info, overrides, err := d.retrieve(ctx, dir, fields, opts)
+	if err != nil {
+		d.logf("status of %s: %v", dir, err)
+	}
+
+	d.mu.Lock()
+	e.st, e.info, e.overrides, e.err = st, info, overrides, err
+	if stop != nil {
+		if d.cache[key] == e {
+			e.stop = stop
+		} else {
+			// Replaced or evicted in the meantime.
+			stop()
+		}
+	}
+	close(e.ready)
+	d.mu.Unlock()
+
+	return st, info, overrides, err
+}
+
+// A repoWatch watches a repository on behalf of the cache entries of the
+// directories in it, so that it's only walked and watched once, whatever the
+// number of directories and options its clients use.
+type repoWatch struct {
+	// ready is closed once the watch has been set up, or has failed to be.
+	ready chan struct{}
+	err   error
+
+	stop    func()
+	entries map[*cacheEntry]bool // entries are the subscribed cache entries.
+}
+
+// watch subscribes e to the changes in the repository dir belongs to, and
+// starts watching it if no other cache entry does. unwatch unsubscribes e, and
+// stops watching the repository after the last entry. It must be called with
+// d.mu held.
+func (d *daemon) watch(ctx context.Context, dir string, e *cacheEntry) (unwatch func(), err error) {
+	if !canWatch {
+		return nil, errors.New("watching repositories is not supported on this platform")
+	}
+
+	dirs, err := repo.LoadDirs(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	w, ok := d.watches[dirs.GitDir]
+	if !ok {
+		w = &repoWatch{ready: make(chan struct{}), entries: make(map[*cacheEntry]bool)}
+		d.watches[dirs.GitDir] = w
+	}
+	w.entries[e] = true
+	d.mu.Unlock()
+
+	unwatch = func() {
+		delete(w.entries, e)
+		if len(w.entries) == 0 && d.watches[dirs.GitDir] == w {
+			delete(d.watches, dirs.GitDir)
+			w.stop()
+		}
+	}
+
+	if !ok {
+		stop, err := watchRepo(ctx, dirs, func() {
+			d.mu.Lock()
+			for e := range w.entries {
+				e.stale.Store(true)
+			}
+			d.mu.Unlock()
+		})
+
+		d.mu.Lock()
+		w.stop, w.err = stop, err
+		if err != nil {
+			delete(d.watches, dirs.GitDir)
+		}
+		close(w.ready)
+		d.mu.Unlock()
+	}
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+		d.mu.Lock()
+		unwatch()
+		d.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return unwatch, nil
+}
+
+// retrieve returns the Git status of dir, the repository information selected
+// by fields and configured by opts and the configuration overrides of the
+// repository. They're retrieved by a worker process running in dir, so that
+// the daemon doesn't change its own working directory, and that Git hanging in
+// a repository doesn't block the others. The worker is killed once ctx is
+// done.
+func (d *daemon) retrieve(ctx context.Context, dir string, fields repo.Fields, opts repo.Options) (*gitstatus.Status, *repo.Info, []cfgOverride, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var req bytes.Buffer
+	if err := gob.NewEncoder(&req).Encode(daemonRequest{Dir: dir, Fields: fields, Opts: opts}); err != nil {
+		return nil, nil, nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, exe, workerFlag)
+	cmd.Dir = dir
+	cmd.Stdin = &req
+	killGroup(cmd)
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, nil, nil, fmt.Errorf("can't retrieve status: %v", ctx.Err())
+	}
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("gitmux worker: %v", err)
+	}
+
+	var resp daemonResponse
+	if err := gob.NewDecoder(bytes.NewReader(out)).Decode(&resp); err != nil {
+		return nil, nil, nil, fmt.Errorf("gitmux worker: %v", err)
+	}
+	if resp.Err != "" {
+		return nil, nil, nil, errors.New(resp.Err)
+	}
+	return resp.Status, resp.Info, resp.Overrides, nil
+}
+
+// workerFlag is the command line flag making gitmux run as a worker of the
+// daemon, see runWorker.
+const workerFlag = "-daemon-worker"
+
+// runWorker reads a daemonRequest from r, and writes to w the daemonResponse
+// holding the Git status of the working directory, the requested repository
+// information and the configuration overrides of the repository.
+func runWorker(r io.Reader, w io.Writer) error {
+	var req daemonRequest
+	if err := gob.NewDecoder(r).Decode(&req); err != nil {
+		return fmt.Errorf("can't read request: %v", err)
+	}
+
+	ctx := context.Background()
+	var resp daemonResponse
+	st, info, err := status(ctx, req.Fields, req.Opts)
+	var overrides []cfgOverride
+	if err == nil {
+		overrides, err = repoOverrides(ctx)
+	}
+	if err != nil {
+		resp.Err = err.Error()
+	} else {
+		resp.Status, resp.Info, resp.Overrides = st, info, overrides
+	}
+	return gob.NewEncoder(w).Encode(resp)
+}
+
+func (d *daemon) logf(format string, args ...any) {
+	if d.dbg {
+		fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	}
+}
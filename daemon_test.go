@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arl/gitmux/repo"
+)
+
+func TestMain(m *testing.M) {
+	// The daemon runs its own executable, the test binary here, as worker.
+	if len(os.Args) == 2 && os.Args[1] == workerFlag {
+		if err := runWorker(os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testRepo creates a Git repository with a single commit and returns its
+// path.
+func testRepo(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=Tester", "-c", "user.email=tester@email.com", "commit", "-q", "--allow-empty", "-m", "Initial commit"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestDaemonReplacedEntry(t *testing.T) {
+	dir := testRepo(t)
+	d := newDaemon(false)
+	defer d.close()
+	ctx := context.Background()
+	key := cacheKey{dir: dir}
+
+	if _, _, _, err := d.status(ctx, dir, repo.Progress, repo.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	first := d.cache[key]
+
+	// The first entry is fresh, but doesn't have the requested fields.
+	if _, _, _, err := d.status(ctx, dir, repo.Commit, repo.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	second := d.cache[key]
+
+	if second == first {
+		t.Fatalf("cache entry hasn't been replaced")
+	}
+	if first.stop != nil {
+		t.Errorf("replaced cache entry is still watching its repository")
+	}
+	if want := repo.Progress | repo.Commit; second.fields != want {
+		t.Errorf("fields = %v, want %v", second.fields, want)
+	}
+}
+
+func TestDaemonOptions(t *testing.T) {
+	dir := testRepo(t)
+	d := newDaemon(false)
+	defer d.close()
+	ctx := context.Background()
+
+	// 2 clients with different options, each asking for the status twice.
+	clients := []repo.Options{{}, {BaseBranch: "main"}}
+	var infos [2]*repo.Info
+	for range 2 {
+		for i, opts := range clients {
+			_, info, _, err := d.status(ctx, dir, repo.Base, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if infos[i] != nil && info != infos[i] {
+				t.Errorf("client %d: status retrieved again instead of served from the cache", i)
+			}
+			infos[i] = info
+		}
+	}
+
+	if len(d.cache) != len(clients) {
+		t.Errorf("got %d cache entries, want %d", len(d.cache), len(clients))
+	}
+}
+
+func TestDaemonHangingRepo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script")
+	}
+
+	// Git hangs in directories holding a 'hang' file.
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\nif [ -e hang ]; then exec sleep 30; fi\nexec " + git + " \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "git"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	hanging, other := testRepo(t), testRepo(t)
+	if err := os.WriteFile(filepath.Join(hanging, "hang"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(timeout time.Duration) { statusTimeout = timeout }(statusTimeout)
+	statusTimeout = time.Second
+
+	d := newDaemon(false)
+	defer d.close()
+	ctx := context.Background()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, _, _, err := d.status(ctx, hanging, 0, repo.Options{})
+		errc <- err
+	}()
+
+	// Another repository isn't blocked in the meantime.
+	start := time.Now()
+	if _, _, _, err := d.status(ctx, other, 0, repo.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= statusTimeout {
+		t.Errorf("status of another repository took %v", elapsed)
+	}
+
+	// The hanging retrieval times out.
+	if err := <-errc; err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
+
+func TestListenPrivateDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes aren't enforced on windows")
+	}
+
+	socket := filepath.Join(t.TempDir(), "gitmux-dir", "gitmux.sock")
+	ln, err := listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	fi, err := os.Stat(filepath.Dir(socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o700 {
+		t.Errorf("socket directory mode = %v, want %v", perm, os.FileMode(0o700))
+	}
+	if !ownedByUser(fi) {
+		t.Errorf("socket directory isn't owned by the current user")
+	}
+}
+
+func TestDaemonSharedWatch(t *testing.T) {
+	if !canWatch {
+		t.Skip("repositories can't be watched on this platform")
+	}
+
+	dir := testRepo(t)
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	d := newDaemon(false)
+	defer d.close()
+	ctx := context.Background()
+
+	// Different directories and options of the same repository.
+	keys := []cacheKey{{dir: dir}, {dir: sub}, {dir: dir, opts: repo.Options{BaseBranch: "main"}}}
+	for _, k := range keys {
+		if _, _, _, err := d.status(ctx, k.dir, 0, k.opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d.mu.Lock()
+	if len(d.watches) != 1 {
+		t.Errorf("got %d watches, want 1", len(d.watches))
+	}
+	d.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		e := d.cache[k]
+		deadline := time.Now().Add(5 * time.Second)
+		for !e.stale.Load() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if !e.stale.Load() {
+			t.Errorf("%+v: change not detected", k)
+		}
+	}
+
+	d.close()
+	if len(d.watches) != 0 {
+		t.Errorf("got %d watches after closing all cache entries, want 0", len(d.watches))
+	}
+}
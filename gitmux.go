@@ -2,15 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/arl/gitstatus"
-	"gopkg.in/yaml.v3"
 
+	"github.com/arl/gitmux/ansi"
 	"github.com/arl/gitmux/json"
+	"github.com/arl/gitmux/prompt"
+	"github.com/arl/gitmux/repo"
 	"github.com/arl/gitmux/tmux"
 )
 
@@ -23,20 +26,40 @@ gitmux prints the status of a Git working tree as a tmux format string.
 If directory is not given, it default to the working directory.  
 
 Options:
-  -cfg FILE       read gitmux config from FILE.
+  -cfg FILE       read gitmux config from FILE. Defaults to $GITMUX_CONFIG,
+                  $XDG_CONFIG_HOME/gitmux/config.yml or ~/.gitmux.yml,
+                  whichever exists first.
+  -profile NAME   merges the NAME profile of the config over the rest of it.
+  -fmt FORMAT     output format: tmux (default), ansi, zsh or bash.
   -printcfg       prints default configuration file.
+  -checkcfg FILE  checks the gitmux config FILE and reports its problems.
+  -cfgschema      prints the JSON Schema of the configuration file.
+  -migratecfg FILE
+                  prints the gitmux config FILE migrated to the current
+                  format, adding new keys with their default values.
   -dbg            outputs Git status as JSON and print errors.
   -timeout DUR    exits if still running after given duration (ex: 2s, 500ms).
+  -daemon         runs as a daemon caching Git status, served to other gitmux
+                  invocations.
+  -socket FILE    unix socket the gitmux daemon listens on.
   -V              prints gitmux version and exits.
 `
 
-func parseOptions() (ctx context.Context, cancel func(), dir string, dbg bool, cfg Config) {
+func parseOptions() (ctx context.Context, cancel func(), dir string, dbg bool, socket string, format string, cfg Config) {
 	var (
 		dbgOpt      = flag.Bool("dbg", false, "")
 		cfgOpt      = flag.String("cfg", "", "")
+		profileOpt  = flag.String("profile", "", "")
+		fmtOpt      = flag.String("fmt", "tmux", "")
 		printCfgOpt = flag.Bool("printcfg", false, "")
+		checkCfgOpt = flag.String("checkcfg", "", "")
+		schemaOpt   = flag.Bool("cfgschema", false, "")
+		migrateOpt  = flag.String("migratecfg", "", "")
 		versionOpt  = flag.Bool("V", false, "")
 		timeoutOpt  = flag.Duration("timeout", 0, "")
+		daemonOpt   = flag.Bool("daemon", false, "")
+		socketOpt   = flag.String("socket", defaultSocket(), "")
+		workerOpt   = flag.Bool(workerFlag[1:], false, "")
 	)
 
 	flag.Usage = func() {
@@ -49,6 +72,12 @@ func parseOptions() (ctx context.Context, cancel func(), dir string, dbg bool, c
 		dir = flag.Arg(0)
 	}
 
+	switch *fmtOpt {
+	case "tmux", "ansi", string(prompt.Zsh), string(prompt.Bash):
+	default:
+		check(fmt.Errorf("unknown output format %q", *fmtOpt), *dbgOpt)
+	}
+
 	if *versionOpt {
 		fmt.Println(version)
 		os.Exit(0)
@@ -59,23 +88,59 @@ func parseOptions() (ctx context.Context, cancel func(), dir string, dbg bool, c
 		os.Exit(0)
 	}
 
-	cfg = defaultCfg
+	if *schemaOpt {
+		check(writeCfgSchema(os.Stdout), *dbgOpt)
+		os.Exit(0)
+	}
 
-	if *cfgOpt != "" {
-		f, err := os.Open(*cfgOpt)
+	if *migrateOpt != "" {
+		out, problems, err := migrateConfig(*migrateOpt)
 		check(err, *dbgOpt)
+		os.Stdout.Write(out)
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		os.Exit(0)
+	}
 
-		dec := yaml.NewDecoder(f)
-		check(dec.Decode(&cfg), *dbgOpt)
+	if *checkCfgOpt != "" {
+		problems, err := checkConfig(*checkCfgOpt)
+		check(err, *dbgOpt)
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		if len(problems) != 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
+	if *workerOpt {
+		check(runWorker(os.Stdin, os.Stdout), *dbgOpt)
+		os.Exit(0)
+	}
+
+	if *daemonOpt {
+		check(runDaemon(*socketOpt, *dbgOpt), *dbgOpt)
+		os.Exit(0)
+	}
+
+	if *cfgOpt == "" {
+		path, err := findConfig()
+		check(err, *dbgOpt)
+		*cfgOpt = path
+	}
+
+	cfg, err := loadConfig(*cfgOpt, *profileOpt)
+	check(err, *dbgOpt)
+
 	if *timeoutOpt != 0 {
 		ctx, cancel = context.WithTimeout(context.Background(), *timeoutOpt)
 	} else {
 		ctx, cancel = context.WithCancel(context.Background())
 	}
 
-	return ctx, cancel, dir, *dbgOpt, cfg
+	return ctx, cancel, dir, *dbgOpt, *socketOpt, *fmtOpt, cfg
 }
 
 func pushdir(dir string) (popdir func() error, err error) {
@@ -91,6 +156,21 @@ func pushdir(dir string) (popdir func() error, err error) {
 	return func() error { return os.Chdir(pwd) }, nil
 }
 
+// status returns the Git status of the current working directory and the
+// repository information selected by fields.
+func status(ctx context.Context, fields repo.Fields, opts repo.Options) (*gitstatus.Status, *repo.Info, error) {
+	st, err := gitstatus.NewWithContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := repo.Load(ctx, st, fields, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return st, info, nil
+}
+
 func check(err error, dbg bool) {
 	if err == nil {
 		return
@@ -104,7 +184,7 @@ func check(err error, dbg bool) {
 }
 
 func main() {
-	ctx, cancel, dir, dbg, cfg := parseOptions()
+	ctx, cancel, dir, dbg, socket, format, cfg := parseOptions()
 	defer cancel()
 
 	// Handle directory change.
@@ -117,17 +197,38 @@ func main() {
 		}()
 	}
 
-	// Retrieve git status.
-	st, err := gitstatus.NewWithContext(ctx)
+	// Retrieve git status, from the daemon if one is running, and merge the
+	// configuration overrides of the repository, if any.
+	st, info, err := daemonStatus(ctx, socket, &cfg)
+	if errors.Is(err, errNoDaemon) {
+		check(mergeRepoConfig(ctx, &cfg), dbg)
+		st, info, err = status(ctx, cfg.Tmux.Fields(), cfg.Tmux.RepoOptions())
+	}
 	check(err, dbg)
 
+	// Keep remote-tracking branches up to date, if enabled.
+	if interval := cfg.Tmux.Options.FetchInterval; interval > 0 && st.RemoteBranch != "" {
+		if err := repo.Fetch(ctx, interval); err != nil && dbg {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+	}
+
 	// Interface that writes a particular representation of a gitstatus.Status
 	type formater interface {
 		Format(io.Writer, *gitstatus.Status) error
 	}
 
 	// Set defauit formater.
-	var fmter formater = &tmux.Formater{Config: cfg.Tmux}
+	var fmter formater = &tmux.Formater{Config: cfg.Tmux, Info: info}
+	switch format {
+	case "ansi":
+		fmter = &ansi.Formater{Formater: tmux.Formater{Config: cfg.Tmux, Info: info}}
+	case string(prompt.Zsh), string(prompt.Bash):
+		fmter = &prompt.Formater{
+			Formater: tmux.Formater{Config: cfg.Tmux, Info: info},
+			Shell:    prompt.Shell(format),
+		}
+	}
 	if dbg {
 		fmter = &json.Formater{}
 	}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arl/gitmux/tmux"
+)
+
+// migrateConfig returns the configuration file at path, migrated to the
+// current configuration format. Keys added since it's been written get their
+// default value, along with their documentation comments, unless one of the
+// files it includes sets them. Everything else, comments and blank lines
+// included, is kept as is, unless keys are added to a flow mapping, in which
+// case the file is encoded again, without its blank lines. Since only known
+// keys are added, the problems of the migrated configuration, such as removed
+// keys, are the ones of the original file, which are returned as with
+// -checkcfg.
+func migrateConfig(path string) ([]byte, []string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var root, def yaml.Node
+	if err := yaml.Unmarshal(buf, &root); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(root.Content) == 0 {
+		return cfgBytes, nil, nil // empty file
+	}
+	if root.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s: expected a mapping at the top level", path)
+	}
+
+	// The file is merged over the files it includes, so a default value
+	// would override theirs. Profiles are merged over the file, they don't
+	// matter.
+	included, err := includedKeys(path, root.Content[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := yaml.Unmarshal(cfgBytes, &def); err != nil {
+		return nil, nil, err
+	}
+	lens := mappingLens(root.Content[0])
+	mergeMissing(root.Content[0], def.Content[0], included)
+
+	out, ok, err := spliceMissing(buf, root.Content[0], lens)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		// Re-encoding keeps the comments, but not the blank lines. Mappings
+		// with new keys and their comments can't stay on a line.
+		for m, n := range lens {
+			if n < len(m.Content) {
+				m.Style &^= yaml.FlowStyle
+			}
+		}
+		if out, err = encodeYAML(&root, 0); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return out, checkConfigData(path, buf), nil
+}
+
+// mappingLens returns the lengths of the content of the mapping node node and
+// of the mappings it holds, recursively.
+func mappingLens(node *yaml.Node) map[*yaml.Node]int {
+	lens := make(map[*yaml.Node]int)
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		lens[n] = len(n.Content)
+		for i := 1; i < len(n.Content); i += 2 {
+			walk(n.Content[i])
+		}
+	}
+	walk(node)
+	return lens
+}
+
+// spliceMissing returns buf, the text of a configuration file of top-level
+// mapping node node, with the keys mergeMissing added to its mappings
+// inserted after their last key, so that the layout of the file, blank lines
+// included, is kept. lens are the lengths of the mappings before the keys were
+// added. It returns false if keys can't be inserted in the text, in a flow or
+// an empty mapping.
+func spliceMissing(buf []byte, node *yaml.Node, lens map[*yaml.Node]int) ([]byte, bool, error) {
+	type insert struct {
+		at   int // at is the index of the line the text is inserted before.
+		text string
+	}
+	var inserts []insert
+
+	lines := strings.SplitAfter(string(buf), "\n")
+	var walk func(m *yaml.Node) (bool, error)
+	walk = func(m *yaml.Node) (bool, error) {
+		n, ok := lens[m]
+		if !ok {
+			return true, nil
+		}
+		if n < len(m.Content) {
+			if n == 0 || m.Style&yaml.FlowStyle != 0 {
+				return false, nil
+			}
+			indent := m.Content[0].Column - 1
+			text, err := encodeYAML(&yaml.Node{Kind: yaml.MappingNode, Content: m.Content[n:]}, indent)
+			if err != nil {
+				return false, err
+			}
+			inserts = append(inserts, insert{at: blockEnd(lines, m.Content[n-2].Line, indent), text: string(text)})
+		}
+		for i := 1; i < n; i += 2 {
+			if ok, err := walk(m.Content[i]); !ok || err != nil {
+				return ok, err
+			}
+		}
+		return true, nil
+	}
+	if ok, err := walk(node); !ok || err != nil {
+		return nil, ok, err
+	}
+
+	// Insert from the bottom so that line indexes stay valid. A mapping and
+	// the last mapping it holds can end on the same line, the keys of the
+	// latter go first.
+	sort.SliceStable(inserts, func(i, j int) bool { return inserts[i].at > inserts[j].at })
+	for _, ins := range inserts {
+		if ins.at > 0 && !strings.HasSuffix(lines[ins.at-1], "\n") {
+			ins.text = "\n" + ins.text
+		}
+		lines = append(lines[:ins.at], append([]string{ins.text}, lines[ins.at:]...)...)
+	}
+	return []byte(strings.Join(lines, "")), true, nil
+}
+
+// blockEnd returns the index of the line following the last line of the
+// block of indentation indent that contains the line numbered line, not
+// counting the blank lines ending it.
+func blockEnd(lines []string, line, indent int) int {
+	end := line
+	for i := line; i < len(lines); i++ {
+		l := strings.TrimRight(lines[i], "\r\n")
+		trimmed := strings.TrimLeft(l, " ")
+		if trimmed == "" {
+			continue
+		}
+		if len(l)-len(trimmed) < indent {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// encodeYAML encodes node with the indentation of the configuration file,
+// shifted right by indent spaces.
+func encodeYAML(node *yaml.Node, indent int) ([]byte, error) {
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(4)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	if indent == 0 {
+		return out.Bytes(), nil
+	}
+
+	prefix := []byte(strings.Repeat(" ", indent))
+	var shifted bytes.Buffer
+	for _, l := range bytes.SplitAfter(out.Bytes(), []byte("\n")) {
+		if len(bytes.TrimSpace(l)) != 0 {
+			shifted.Write(prefix)
+		}
+		shifted.Write(l)
+	}
+	return shifted.Bytes(), nil
+}
+
+// mergeMissing adds to the mapping node dst the keys of the mapping node src
+// it doesn't have, recursively, except those of the mapping node skip, if not
+// nil.
+func mergeMissing(dst, src, skip *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, val := src.Content[i], src.Content[i+1]
+
+		var skipped *yaml.Node
+		if skip != nil {
+			skipped = tmux.MappingValue(skip, key.Value)
+		}
+
+		cur := tmux.MappingValue(dst, key.Value)
+		switch {
+		case cur == nil && skipped == nil:
+			dst.Content = append(dst.Content, key, val)
+		case cur == nil && skipped.Kind == yaml.MappingNode && val.Kind == yaml.MappingNode:
+			// Only add the keys of the section that are skipped.
+			m := &yaml.Node{Kind: yaml.MappingNode}
+			mergeMissing(m, val, skipped)
+			if len(m.Content) > 0 {
+				dst.Content = append(dst.Content, key, m)
+			}
+		case cur != nil && cur.Kind == yaml.MappingNode && val.Kind == yaml.MappingNode:
+			mergeMissing(cur, val, skipped)
+		}
+	}
+}
+
+// includedKeys returns a mapping node holding the keys set by the files that
+// the configuration file at path, of top-level mapping node node, includes,
+// recursively.
+func includedKeys(path string, node *yaml.Node) (*yaml.Node, error) {
+	keys := &yaml.Node{Kind: yaml.MappingNode}
+	loading := make(map[string]bool)
+
+	var walk func(path string, node *yaml.Node) error
+	walk = func(path string, node *yaml.Node) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if loading[abs] {
+			return fmt.Errorf("%s: include cycle", path)
+		}
+		loading[abs] = true
+		defer delete(loading, abs)
+
+		var incs []string
+		if n := tmux.MappingValue(node, "include"); n != nil {
+			if err := n.Decode(&incs); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+		}
+
+		for _, inc := range incs {
+			inc, err := includePath(path, inc)
+			if err != nil {
+				return err
+			}
+			buf, err := os.ReadFile(inc)
+			if err != nil {
+				return err
+			}
+
+			var doc yaml.Node
+			if err := yaml.Unmarshal(buf, &doc); err != nil {
+				return fmt.Errorf("%s: %v", inc, err)
+			}
+			if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+				continue
+			}
+			mergeMissing(keys, doc.Content[0], nil)
+			if err := walk(inc, doc.Content[0]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(path, node); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,126 @@
+// Package prompt formats the Git status as a shell prompt string, with the
+// same configuration as the tmux status bar.
+package prompt
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/arl/gitstatus"
+
+	"github.com/arl/gitmux/ansi"
+	"github.com/arl/gitmux/tmux"
+)
+
+// A Shell is a shell whose prompt strings can be formatted.
+type Shell string
+
+const (
+	Zsh  Shell = "zsh"
+	Bash Shell = "bash"
+)
+
+// A Formater formats the Git status as a prompt string for Shell. It formats
+// it as the tmux Formater would, and translates tmux styles to the escapes of
+// the shell prompt language.
+type Formater struct {
+	tmux.Formater
+	Shell Shell
+}
+
+// Format writes st as a prompt string into w.
+func (f *Formater) Format(w io.Writer, st *gitstatus.Status) error {
+	if f.Shell != Zsh && f.Shell != Bash {
+		return fmt.Errorf("unsupported shell %q", f.Shell)
+	}
+
+	var sb strings.Builder
+	if err := f.Formater.Format(&sb, st); err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	for _, span := range tmux.Spans(sb.String()) {
+		if f.Shell == Zsh {
+			writeZsh(&out, span)
+		} else {
+			writeBash(&out, span)
+		}
+	}
+
+	// Don't let styles leak into the rest of the prompt.
+	switch f.Shell {
+	case Zsh:
+		out.WriteString("%f%k%b%u%s%{\x1b[0m%}")
+	case Bash:
+		out.WriteString(`\[\e[0m\]`)
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// writeZsh writes span using zsh prompt escapes: %F{..} and %K{..} for colors,
+// %B, %U and %S for bold, underscore and reverse. Other attributes are set
+// with raw SGR sequences.
+func writeZsh(sb *strings.Builder, span tmux.Span) {
+	st := span.Style
+
+	// Start from a clean state, both for zsh and the terminal.
+	sb.WriteString("%f%k%b%u%s%{\x1b[0m%}")
+	if c, ok := zshColor(st.Fg); ok {
+		fmt.Fprintf(sb, "%%F{%s}", c)
+	}
+	if c, ok := zshColor(st.Bg); ok {
+		fmt.Fprintf(sb, "%%K{%s}", c)
+	}
+	if st.Attrs&tmux.AttrBold != 0 {
+		sb.WriteString("%B")
+	}
+	if st.Attrs&tmux.AttrUnderscore != 0 {
+		sb.WriteString("%U")
+	}
+	if st.Attrs&tmux.AttrReverse != 0 {
+		sb.WriteString("%S")
+	}
+
+	const native = tmux.AttrBold | tmux.AttrUnderscore | tmux.AttrReverse
+	if attrs := st.Attrs &^ native; attrs != 0 {
+		fmt.Fprintf(sb, "%%{\x1b[%sm%%}", ansi.AttrSGR(attrs))
+	}
+
+	// A literal % is written %%.
+	sb.WriteString(strings.ReplaceAll(span.Text, "%", "%%"))
+}
+
+// zshColor returns the zsh name of c, or false if it's the default color.
+func zshColor(c tmux.Color) (string, bool) {
+	switch c.Kind {
+	case tmux.ColorBasic:
+		if c.Value < len(tmux.ColorNames) {
+			return tmux.ColorNames[c.Value], true
+		}
+		return strconv.Itoa(c.Value), true
+	case tmux.Color256:
+		return strconv.Itoa(c.Value), true
+	case tmux.ColorRGB:
+		return fmt.Sprintf("#%06x", c.Value), true
+	}
+	return "", false
+}
+
+// writeBash writes span using SGR sequences, enclosed in \[ and \] so that
+// bash doesn't count them in the prompt length.
+func writeBash(sb *strings.Builder, span tmux.Span) {
+	fmt.Fprintf(sb, `\[\e[%sm\]`, ansi.SGR(span.Style))
+
+	bashEscaper.WriteString(sb, span.Text)
+}
+
+// bashEscaper escapes the characters of a text written in a bash prompt.
+// Backslashes are decoded twice, first as prompt escapes, then, since
+// promptvars is on by default, by the expansion of the prompt, which must not
+// see a $ or a ` that could run a command.
+var bashEscaper = strings.NewReplacer(`\`, `\\\\`, `$`, `\\$`, "`", "\\\\`")
@@ -0,0 +1,102 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arl/gitstatus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/arl/gitmux/tmux"
+)
+
+const testCfg = `
+symbols:
+    branch: "⎇ "
+    modified: "%"
+styles:
+    clear: "#[none]"
+    branch: "#[fg=white,bold]"
+    modified: "#[fg=colour208,italics]"
+    stashed: "#[fg=#ff8000,bg=blue,reverse]"
+layout: [branch, ' \ ', flags]
+`
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		shell Shell
+		st    *gitstatus.Status
+		want  string
+	}{
+		{
+			shell: Zsh,
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "main", NumModified: 2},
+			},
+			want: "%f%k%b%u%s%{\x1b[0m%}%F{white}%B⎇ main" +
+				"%f%k%b%u%s%{\x1b[0m%}%F{white} \\ " +
+				"%f%k%b%u%s%{\x1b[0m%}%F{208}%{\x1b[3m%}%%2" +
+				"%f%k%b%u%s%{\x1b[0m%}",
+		},
+		{
+			shell: Zsh,
+			st: &gitstatus.Status{
+				Porcelain:  gitstatus.Porcelain{LocalBranch: "main"},
+				NumStashed: 1,
+			},
+			want: "%f%k%b%u%s%{\x1b[0m%}%F{white}%B⎇ main" +
+				"%f%k%b%u%s%{\x1b[0m%}%F{white} \\ " +
+				"%f%k%b%u%s%{\x1b[0m%}%F{#ff8000}%K{blue}%S1" +
+				"%f%k%b%u%s%{\x1b[0m%}",
+		},
+		{
+			shell: Bash,
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "main", NumModified: 2},
+			},
+			want: `\[\e[0;1;37m\]⎇ main\[\e[0;37m\] \\\\ \[\e[0;3;38;5;208m\]%2\[\e[0m\]`,
+		},
+		{
+			shell: Bash,
+			st: &gitstatus.Status{
+				Porcelain:  gitstatus.Porcelain{LocalBranch: "main"},
+				NumStashed: 1,
+			},
+			want: `\[\e[0;1;37m\]⎇ main\[\e[0;37m\] \\\\ \[\e[0;7;38;2;255;128;0;44m\]1\[\e[0m\]`,
+		},
+		{
+			// Nothing in the branch name gets expanded by bash.
+			shell: Bash,
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "$(touch${IFS}/tmp/pw/PWNED)`id`\\"},
+			},
+			want: `\[\e[0;1;37m\]⎇ \\$(touch\\${IFS}/tmp/pw/PWNED)\\` + "`" + `id\\` + "`" + `\\\\\[\e[0;37m\] \\\\ \[\e[0m\]`,
+		},
+	}
+
+	var cfg tmux.Config
+	if err := yaml.Unmarshal([]byte(testCfg), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.shell), func(t *testing.T) {
+			f := &Formater{Formater: tmux.Formater{Config: cfg}, Shell: tt.shell}
+			var sb strings.Builder
+			if err := f.Format(&sb, tt.st); err != nil {
+				t.Fatal(err)
+			}
+			if got := sb.String(); got != tt.want {
+				t.Errorf("Format() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatUnknownShell(t *testing.T) {
+	f := &Formater{Shell: "fish"}
+	var sb strings.Builder
+	if err := f.Format(&sb, &gitstatus.Status{}); err == nil {
+		t.Errorf("Format() with shell %q: got nil error", f.Shell)
+	}
+}
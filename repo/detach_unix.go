@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package repo
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach makes cmd run in its own session, so that it survives its parent and
+// has no controlling terminal.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
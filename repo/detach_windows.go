@@ -0,0 +1,12 @@
+package repo
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach makes cmd run without a console, so that it survives its parent.
+func detach(cmd *exec.Cmd) {
+	const detachedProcess = 0x00000008
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess}
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fetchStamp is the file, in the common Git directory, whose modification time
+// is the time the last background fetch has been started.
+const fetchStamp = "gitmux-fetch"
+
+// staleLock is the age after which the lock of fetchStamp is considered a
+// leftover of a gitmux process killed while holding it.
+const staleLock = 10 * time.Second
+
+// Fetch starts 'git fetch' in the background for the repository of the current
+// working directory, unless one has already been started less than interval
+// ago. It doesn't wait for the fetch to complete.
+func Fetch(ctx context.Context, interval time.Duration) error {
+	out, err := git(ctx, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return err
+	}
+
+	stamp := filepath.Join(strings.TrimSpace(string(out)), fetchStamp)
+	ok, err := claimFetch(stamp, interval)
+	if !ok || err != nil {
+		return err
+	}
+
+	// The fetch must outlive gitmux, so it doesn't depend on ctx. It must not
+	// prompt for credentials either, nobody would answer.
+	cmd := exec.Command("git", "fetch", "--quiet")
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "GIT_TERMINAL_PROMPT=0")
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// claimFetch reports whether a fetch can be started, that is whether stamp is
+// older than interval, in which case it touches it. Concurrent calls, even
+// from different processes, can't both claim the same fetch.
+func claimFetch(stamp string, interval time.Duration) (bool, error) {
+	if fi, err := os.Stat(stamp); err == nil && time.Since(fi.ModTime()) < interval {
+		return false, nil
+	}
+
+	// Checking and touching the stamp must be atomic, so it's done while
+	// holding a lock file that only one gitmux process can create.
+	lock := stamp + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(lock)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(lock)
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+
+	// Another gitmux may have touched the stamp since it's been checked.
+	if fi, err := os.Stat(stamp); err == nil && time.Since(fi.ModTime()) < interval {
+		return false, nil
+	}
+
+	f, err = os.Create(stamp)
+	if err != nil {
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClaimFetch(t *testing.T) {
+	stamp := filepath.Join(t.TempDir(), fetchStamp)
+	claim := func() bool {
+		t.Helper()
+		ok, err := claimFetch(stamp, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if !claim() {
+		t.Fatalf("first fetch not claimed")
+	}
+	if claim() {
+		t.Fatalf("fetch claimed again before the interval")
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stamp, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only one of concurrent claims succeeds.
+	var (
+		wg      sync.WaitGroup
+		claimed atomic.Int32
+	)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, err := claimFetch(stamp, time.Hour); err == nil && ok {
+				claimed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := claimed.Load(); n != 1 {
+		t.Fatalf("fetch claimed %d times, want 1", n)
+	}
+
+	// A lock left by a killed process prevents the claim, then gets removed.
+	if err := os.Chtimes(stamp, old, old); err != nil {
+		t.Fatal(err)
+	}
+	lock := stamp + ".lock"
+	if err := os.WriteFile(lock, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if claim() {
+		t.Fatalf("fetch claimed while locked")
+	}
+	if !claim() {
+		t.Fatalf("fetch not claimed after the stale lock removal")
+	}
+}
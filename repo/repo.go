@@ -0,0 +1,684 @@
+// Package repo retrieves information about a Git repository that
+// gitstatus.Status doesn't provide.
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arl/gitstatus"
+)
+
+// Fields selects the information to retrieve.
+type Fields uint
+
+const (
+	// Progress selects the progress of the rebase or am in progress.
+	Progress Fields = 1 << iota
+
+	// Target selects the target of the operation in progress.
+	Target
+
+	// Worktree selects the name of the linked worktree.
+	Worktree
+
+	// Submodules selects the submodules summary.
+	Submodules
+
+	// Toplevel selects the path of the top-level directory of the working tree.
+	Toplevel
+
+	// Subdir selects the path of the current directory relative to the
+	// top-level directory.
+	Subdir
+
+	// Commit selects the subject, author and date of the last commit.
+	Commit
+
+	// LastFetch selects the time of the last fetch.
+	LastFetch
+
+	// Base selects the divergence with the base branch.
+	Base
+
+	// Push selects the divergence with the push branch.
+	Push
+
+	// Upstream selects whether the upstream branch is gone.
+	Upstream
+
+	// All selects all the information.
+	All Fields = ^Fields(0)
+)
+
+// Info holds information about a Git repository.
+type Info struct {
+	// Step is the current step of the rebase or am in progress, and Total its
+	// total number of steps. Both are zero if unknown.
+	Step, Total int
+
+	// Target is the name of the branch, or the abbreviated hash of the commit,
+	// being rebased onto, merged, cherry-picked or reverted. It's empty if no
+	// such operation is in progress.
+	Target string
+
+	// Worktree is the name of the directory of the linked worktree, empty in
+	// the main worktree.
+	Worktree string
+
+	// Submodules summarizes the state of the submodules.
+	Submodules SubmodulesSummary
+
+	// Toplevel is the absolute path of the top-level directory of the
+	// working tree.
+	Toplevel string
+
+	// Subdir is the path of the current directory relative to the top-level
+	// directory of the working tree. It's empty in the top-level directory.
+	Subdir string
+
+	// Commit describes the last commit. It's empty if there's no commit yet.
+	Commit CommitInfo
+
+	// LastFetch is the time of the last fetch, as per the modification time
+	// of FETCH_HEAD or, in a clone that hasn't been fetched since, of the
+	// remote-tracking refs of the upstream remote. It's zero if the repository
+	// has never been fetched.
+	LastFetch time.Time
+
+	// NeverFetched reports whether the repository has never been fetched,
+	// neither by a fetch nor by a clone.
+	NeverFetched bool
+
+	// Base is the divergence between HEAD and the base branch. It's empty if
+	// there's no base branch.
+	Base Divergence
+
+	// Push is the divergence between HEAD and the branch it would be pushed
+	// to, @{push}. It's empty if there's no such branch, or if it's the
+	// upstream branch.
+	Push Divergence
+
+	// UpstreamGone reports whether the upstream branch is configured but
+	// doesn't exist anymore, for example after it's been deleted on the remote
+	// and pruned.
+	UpstreamGone bool
+}
+
+// InfoFields maps the names of the fields of Info to the Fields selecting
+// them.
+var InfoFields = map[string]Fields{
+	"Step":         Progress,
+	"Total":        Progress,
+	"Target":       Target,
+	"Worktree":     Worktree,
+	"Submodules":   Submodules,
+	"Toplevel":     Toplevel,
+	"Subdir":       Subdir,
+	"Commit":       Commit,
+	"LastFetch":    LastFetch,
+	"NeverFetched": LastFetch,
+	"Base":         Base,
+	"Push":         Push,
+	"UpstreamGone": Upstream,
+}
+
+// Options configures the retrieval of the repository information.
+type Options struct {
+	// BaseBranch is the branch the Base divergence is computed against. If
+	// empty, the default branch of the origin remote is used.
+	BaseBranch string
+}
+
+// Divergence describes how HEAD and another ref diverge.
+type Divergence struct {
+	// Ref is the name of the ref HEAD is compared to.
+	Ref string
+
+	// Ahead is the number of commits in HEAD but not in Ref, and Behind the
+	// number of commits in Ref but not in HEAD.
+	Ahead, Behind int
+}
+
+// CommitInfo describes a commit.
+type CommitInfo struct {
+	// Subject is the first line of the commit message.
+	Subject string
+
+	// Author is the name of the commit author.
+	Author string
+
+	// Time is the committer date.
+	Time time.Time
+}
+
+// SubmodulesSummary holds the number of submodules in specific states. A
+// submodule may be counted in both OutOfSync and Dirty.
+type SubmodulesSummary struct {
+	// Uninitialized is the number of submodules not initialized.
+	Uninitialized int
+
+	// OutOfSync is the number of submodules whose checked out commit doesn't
+	// match the one recorded in the superproject.
+	OutOfSync int
+
+	// Dirty is the number of submodules with modified or untracked files.
+	Dirty int
+}
+
+// Load retrieves the information selected by fields about the repository of
+// the current working directory, whose status is st.
+func Load(ctx context.Context, st *gitstatus.Status, fields Fields, opts Options) (*Info, error) {
+	l := loader{ctx: ctx, st: st, opts: opts}
+	info := &Info{}
+
+	loaders := []struct {
+		fields Fields
+		load   func(*Info) error
+	}{
+		{Progress, l.progress},
+		{Target, l.target},
+		{Worktree, l.worktree},
+		{Submodules, l.submodules},
+		{Toplevel, l.toplevel},
+		{Subdir, l.subdir},
+		{Commit, l.commit},
+		{LastFetch, l.lastFetch},
+		{Base, l.base},
+		{Push, l.push},
+		{Upstream, l.upstream},
+	}
+	for _, ld := range loaders {
+		if fields&ld.fields == 0 {
+			continue
+		}
+		if err := ld.load(info); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+type loader struct {
+	ctx  context.Context
+	st   *gitstatus.Status
+	opts Options
+
+	dirs *Dirs // cached
+}
+
+// repoDirs returns the directories of the repository.
+func (l *loader) repoDirs() (*Dirs, error) {
+	if l.dirs != nil {
+		return l.dirs, nil
+	}
+
+	dirs, err := LoadDirs(l.ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	l.dirs = &dirs
+	return l.dirs, nil
+}
+
+// gitDir returns the absolute path of the Git directory.
+func (l *loader) gitDir() (string, error) {
+	dirs, err := l.repoDirs()
+	if err != nil {
+		return "", err
+	}
+	return dirs.GitDir, nil
+}
+
+// topLevel returns the absolute path of the top-level directory of the
+// working tree.
+func (l *loader) topLevel() (string, error) {
+	dirs, err := l.repoDirs()
+	if err != nil {
+		return "", err
+	}
+	return dirs.Toplevel, nil
+}
+
+func (l *loader) progress(info *Info) error {
+	switch l.st.State {
+	case gitstatus.Rebasing, gitstatus.AM, gitstatus.AMRebase:
+	default:
+		return nil
+	}
+
+	gitdir, err := l.gitDir()
+	if err != nil {
+		return err
+	}
+
+	// Interactive and merge-based rebases store their progress in
+	// rebase-merge, am and apply-based rebases in rebase-apply.
+	for _, files := range [][3]string{
+		{"rebase-merge", "msgnum", "end"},
+		{"rebase-apply", "next", "last"},
+	} {
+		step, ok1 := readInt(filepath.Join(gitdir, files[0], files[1]))
+		total, ok2 := readInt(filepath.Join(gitdir, files[0], files[2]))
+		if ok1 && ok2 {
+			info.Step, info.Total = step, total
+			break
+		}
+	}
+	return nil
+}
+
+func (l *loader) target(info *Info) error {
+	var files []string
+	switch l.st.State {
+	case gitstatus.Rebasing, gitstatus.AMRebase:
+		files = []string{"rebase-merge/onto", "rebase-apply/onto"}
+	case gitstatus.Merging:
+		files = []string{"MERGE_HEAD"}
+	case gitstatus.CherryPicking:
+		files = []string{"CHERRY_PICK_HEAD"}
+	case gitstatus.Reverting:
+		files = []string{"REVERT_HEAD"}
+	default:
+		return nil
+	}
+
+	gitdir, err := l.gitDir()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		buf, err := os.ReadFile(filepath.Join(gitdir, file))
+		if err != nil {
+			continue
+		}
+
+		// MERGE_HEAD has one line per merged commit, only consider the first.
+		sha, _, _ := strings.Cut(string(buf), "\n")
+		if sha = strings.TrimSpace(sha); sha == "" {
+			continue
+		}
+
+		info.Target, err = l.refName(sha)
+		return err
+	}
+	return nil
+}
+
+// refName returns the short name of a branch pointing at the commit sha, local
+// branches first, or the abbreviated commit hash if there's none.
+func (l *loader) refName(sha string) (string, error) {
+	out, err := git(l.ctx, "for-each-ref", "--points-at="+sha, "--format=%(refname:short)", "refs/heads", "refs/remotes")
+	if err != nil {
+		return "", err
+	}
+	if name, _, _ := strings.Cut(string(out), "\n"); name != "" {
+		return name, nil
+	}
+
+	out, err = git(l.ctx, "rev-parse", "--short", sha)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (l *loader) worktree(info *Info) error {
+	gitdir, err := l.gitDir()
+	if err != nil {
+		return err
+	}
+
+	// Linked worktrees have their own Git directory, which refers to the Git
+	// directory of the main worktree in its 'commondir' file. It's not named
+	// after the worktree directory if that's been moved, or if its name was
+	// already taken, so the worktree is named after its directory.
+	if _, err := os.Stat(filepath.Join(gitdir, "commondir")); err != nil {
+		return nil
+	}
+
+	top, err := l.topLevel()
+	if err != nil {
+		return err
+	}
+	info.Worktree = filepath.Base(top)
+	return nil
+}
+
+func (l *loader) toplevel(info *Info) error {
+	top, err := l.topLevel()
+	if err != nil {
+		return err
+	}
+
+	info.Toplevel = top
+	return nil
+}
+
+func (l *loader) subdir(info *Info) error {
+	out, err := git(l.ctx, "rev-parse", "--show-prefix")
+	if err != nil {
+		return err
+	}
+
+	if prefix := strings.TrimSpace(string(out)); prefix != "" {
+		info.Subdir = filepath.Clean(prefix)
+	}
+	return nil
+}
+
+func (l *loader) commit(info *Info) error {
+	if l.st.IsInitial {
+		return nil
+	}
+
+	out, err := git(l.ctx, "log", "-1", "--no-show-signature", "--format=%ct%x00%an%x00%s")
+	if err != nil {
+		return err
+	}
+
+	parts := strings.SplitN(strings.TrimSuffix(string(out), "\n"), "\x00", 3)
+	if len(parts) != 3 {
+		return fmt.Errorf("unexpected git log output: %q", out)
+	}
+
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("unexpected git log output: %q", out)
+	}
+
+	info.Commit = CommitInfo{
+		Time:    time.Unix(ts, 0),
+		Author:  parts[1],
+		Subject: parts[2],
+	}
+	return nil
+}
+
+func (l *loader) lastFetch(info *Info) error {
+	paths := []string{"FETCH_HEAD"}
+
+	// git clone doesn't write FETCH_HEAD: until the first fetch, the
+	// remote-tracking refs of the upstream remote, written by the clone, tell
+	// when the remote has last been fetched.
+	if l.st.LocalBranch != "" && l.st.RemoteBranch != "" {
+		out, err := git(l.ctx, "for-each-ref", "--format=%(upstream) %(upstream:remotename)", "refs/heads/"+l.st.LocalBranch)
+		if err != nil {
+			return err
+		}
+		ref, remote, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+		if ref != "" && remote != "" && remote != "." {
+			paths = append(paths, "logs/"+ref, "refs/remotes/"+remote, "packed-refs")
+		}
+	}
+
+	args := []string{"rev-parse"}
+	for _, path := range paths {
+		args = append(args, "--git-path", path)
+	}
+	out, err := git(l.ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fi, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		info.LastFetch = fi.ModTime()
+		return nil
+	}
+
+	info.NeverFetched = true
+	return nil
+}
+
+func (l *loader) base(info *Info) error {
+	if l.st.IsInitial {
+		return nil
+	}
+
+	ref := l.opts.BaseBranch
+	if ref == "" {
+		out, err := git(l.ctx, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+		if err != nil {
+			return nil // no default branch
+		}
+		ref = strings.TrimSpace(string(out))
+	}
+
+	// Don't fail on a base branch that doesn't exist (yet), just show nothing.
+	if _, err := git(l.ctx, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil
+	}
+
+	div, err := l.divergence(ref)
+	if err != nil {
+		return err
+	}
+
+	info.Base = div
+	return nil
+}
+
+func (l *loader) push(info *Info) error {
+	if l.st.IsInitial || l.st.IsDetached {
+		return nil
+	}
+
+	// Fails if there's no push branch, or if it hasn't been pushed yet.
+	out, err := git(l.ctx, "rev-parse", "--abbrev-ref", "@{push}")
+	if err != nil {
+		return nil
+	}
+
+	// In non-triangular workflows, that's already the upstream divergence.
+	ref := strings.TrimSpace(string(out))
+	if ref == l.st.RemoteBranch {
+		return nil
+	}
+
+	div, err := l.divergence(ref)
+	if err != nil {
+		return err
+	}
+
+	info.Push = div
+	return nil
+}
+
+func (l *loader) upstream(info *Info) error {
+	if l.st.RemoteBranch == "" {
+		return nil
+	}
+	if l.st.AheadCount != 0 || l.st.BehindCount != 0 {
+		// Git only counts commits against an existing upstream branch.
+		return nil
+	}
+
+	// The upstream branch is configured, so failing to resolve it means it's
+	// gone.
+	_, err := git(l.ctx, "rev-parse", "--verify", "--quiet", "@{upstream}")
+	info.UpstreamGone = err != nil
+	return nil
+}
+
+// divergence returns the divergence between HEAD and ref.
+func (l *loader) divergence(ref string) (Divergence, error) {
+	out, err := git(l.ctx, "rev-list", "--left-right", "--count", ref+"...HEAD")
+	if err != nil {
+		return Divergence{}, err
+	}
+
+	var div Divergence
+	if _, err := fmt.Sscan(string(out), &div.Behind, &div.Ahead); err != nil {
+		return Divergence{}, fmt.Errorf("unexpected git rev-list output: %q", out)
+	}
+	div.Ref = ref
+	return div, nil
+}
+
+func (l *loader) submodules(info *Info) error {
+	out, err := git(l.ctx, "submodule", "status")
+	if err != nil {
+		return err
+	}
+
+	sum, paths := parseSubmoduleStatus(string(out))
+	info.Submodules = sum
+	if len(paths) == 0 {
+		return nil // no initialized submodules
+	}
+
+	// Only look at the submodules, the rest of the working tree has already
+	// been looked at by gitstatus.
+	args := []string{"status", "--porcelain=v2", "--ignore-submodules=none", "--"}
+	for _, path := range paths {
+		args = append(args, ":(literal)"+path)
+	}
+	out, err = git(l.ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	// Changed entries are described by lines of the form:
+	//   1 <XY> <sub> ...
+	//   2 <XY> <sub> ...
+	//   u <XY> <sub> ...
+	// where, for a submodule, <sub> is "S<c><m><u>", with <m> set to 'M' if
+	// it has tracked changes and <u> set to 'U' if it has untracked files.
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || (fields[0] != "1" && fields[0] != "2" && fields[0] != "u") {
+			continue
+		}
+		if sub := fields[2]; len(sub) == 4 && sub[0] == 'S' && (sub[2] == 'M' || sub[3] == 'U') {
+			info.Submodules.Dirty++
+		}
+	}
+	return nil
+}
+
+// parseSubmoduleStatus parses the output of 'git submodule status'. It returns
+// the counts of uninitialized and out of sync submodules, and the paths of the
+// initialized ones.
+func parseSubmoduleStatus(out string) (sum SubmodulesSummary, paths []string) {
+	// Each line is of the form:
+	//   <flag><hash> <path>[ (<describe>)]
+	// where flag is '-' if the submodule is not initialized, '+' if the
+	// checked out commit doesn't match the recorded one, and ' ' or 'U'
+	// otherwise. Uninitialized submodules have no describe part.
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		_, path, ok := strings.Cut(line[1:], " ")
+		if !ok {
+			continue
+		}
+
+		switch line[0] {
+		case '-':
+			sum.Uninitialized++
+			continue
+		case '+':
+			sum.OutOfSync++
+		}
+
+		if i := strings.LastIndex(path, " ("); i != -1 && strings.HasSuffix(path, ")") {
+			path = path[:i]
+		}
+		paths = append(paths, path)
+	}
+	return sum, paths
+}
+
+// readInt reads the integer contained in the file at path.
+func readInt(path string) (int, bool) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+	return n, err == nil
+}
+
+// Dirs are the directories of a repository.
+type Dirs struct {
+	// GitDir is the absolute path of the Git directory.
+	GitDir string
+
+	// CommonDir is the absolute path of the common Git directory, the Git
+	// directory except in a linked worktree.
+	CommonDir string
+
+	// Toplevel is the absolute path of the top-level directory of the working
+	// tree.
+	Toplevel string
+}
+
+// LoadDirs returns the directories of the repository dir belongs to, or the
+// current working directory if dir is empty.
+func LoadDirs(ctx context.Context, dir string) (Dirs, error) {
+	out, err := Git(ctx, dir, "rev-parse", "--absolute-git-dir", "--git-common-dir", "--show-toplevel")
+	if err != nil {
+		return Dirs{}, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 3 {
+		return Dirs{}, fmt.Errorf("unexpected git rev-parse output: %q", out)
+	}
+
+	// The common directory is relative to dir, unless it's elsewhere.
+	common := lines[1]
+	if !filepath.IsAbs(common) {
+		if dir == "" {
+			dir = "."
+		}
+		if common, err = filepath.Abs(filepath.Join(dir, common)); err != nil {
+			return Dirs{}, err
+		}
+	}
+
+	return Dirs{
+		GitDir:    filepath.Clean(lines[0]),
+		CommonDir: filepath.Clean(common),
+		Toplevel:  filepath.Clean(lines[2]),
+	}, nil
+}
+
+// git runs git with args in the current working directory and returns its
+// standard output.
+func git(ctx context.Context, args ...string) ([]byte, error) {
+	return Git(ctx, "", args...)
+}
+
+// Git runs git with args in dir, or in the current working directory if dir is
+// empty, and returns its standard output.
+func Git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "GIT_OPTIONAL_LOCKS=0")
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec git '%s': %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arl/gitstatus"
+)
+
+// writeFiles creates the files in dir, with the given contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       gitstatus.TreeState
+		files       map[string]string
+		step, total int
+	}{
+		{
+			name:  "rebase-merge",
+			state: gitstatus.Rebasing,
+			files: map[string]string{
+				"rebase-merge/msgnum": "3\n",
+				"rebase-merge/end":    "7\n",
+			},
+			step:  3,
+			total: 7,
+		},
+		{
+			name:  "rebase-apply",
+			state: gitstatus.AM,
+			files: map[string]string{
+				"rebase-apply/next": "1\n",
+				"rebase-apply/last": "2\n",
+			},
+			step:  1,
+			total: 2,
+		},
+		{
+			name:  "missing file",
+			state: gitstatus.Rebasing,
+			files: map[string]string{
+				"rebase-merge/msgnum": "3\n",
+			},
+		},
+		{
+			name:  "invalid content",
+			state: gitstatus.AMRebase,
+			files: map[string]string{
+				"rebase-apply/next": "foo\n",
+				"rebase-apply/last": "2\n",
+			},
+		},
+		{
+			name:  "not rebasing",
+			state: gitstatus.Merging,
+			files: map[string]string{
+				"rebase-merge/msgnum": "3\n",
+				"rebase-merge/end":    "7\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitdir := t.TempDir()
+			writeFiles(t, gitdir, tt.files)
+
+			l := loader{
+				ctx:  context.Background(),
+				st:   &gitstatus.Status{State: tt.state},
+				dirs: &Dirs{GitDir: gitdir},
+			}
+
+			info := &Info{}
+			if err := l.progress(info); err != nil {
+				t.Fatalf("progress error: %v", err)
+			}
+			if info.Step != tt.step || info.Total != tt.total {
+				t.Errorf("got %d/%d, want %d/%d", info.Step, info.Total, tt.step, tt.total)
+			}
+		})
+	}
+}
+
+func Test_parseSubmoduleStatus(t *testing.T) {
+	const out = ` 1111111111111111111111111111111111111111 a (heads/main)
++2222222222222222222222222222222222222222 sub/b (v1.0-1-g2222222)
+-3333333333333333333333333333333333333333 c
+U4444444444444444444444444444444444444444 with space (heads/main)
+`
+	sum, paths := parseSubmoduleStatus(out)
+
+	wantSum := SubmodulesSummary{Uninitialized: 1, OutOfSync: 1}
+	if sum != wantSum {
+		t.Errorf("summary = %+v, want %+v", sum, wantSum)
+	}
+	wantPaths := []string{"a", "sub/b", "with space"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("paths = %q, want %q", paths, wantPaths)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A jsonSchemaer describes its own JSON Schema, ref returning a reference to
+// the JSON Schema of the type of its argument.
+type jsonSchemaer interface {
+	JSONSchema(ref func(any) map[string]any) map[string]any
+}
+
+// writeCfgSchema writes the JSON Schema of the configuration file to w.
+func writeCfgSchema(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cfgSchema())
+}
+
+// cfgSchema returns the JSON Schema of the configuration file, derived from
+// the Config type.
+func cfgSchema() map[string]any {
+	g := schemaGen{defs: make(map[string]any)}
+
+	root := g.structSchema(reflect.TypeOf(Config{}))
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "gitmux configuration"
+	root["definitions"] = g.defs
+	return root
+}
+
+// schemaGen generates JSON Schemas from Go types. Named types get their own
+// definition, referenced wherever they're used.
+type schemaGen struct {
+	defs map[string]any
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	yamlNodeType = reflect.TypeOf(yaml.Node{})
+	schemaerType = reflect.TypeOf((*jsonSchemaer)(nil)).Elem()
+)
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	switch t {
+	case durationType:
+		return map[string]any{
+			"type":    "string",
+			"pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+		}
+	case yamlNodeType:
+		// Only used for profiles, which are configurations.
+		return map[string]any{"$ref": "#"}
+	}
+
+	if t.PkgPath() != "" && (t.Kind() == reflect.Struct || t.Implements(schemaerType)) {
+		return g.ref(t)
+	}
+	return g.inline(t)
+}
+
+// ref returns a reference to the definition of t, adding it if needed.
+func (g *schemaGen) ref(t reflect.Type) map[string]any {
+	name := path.Base(t.PkgPath()) + "." + t.Name()
+	ref := map[string]any{"$ref": "#/definitions/" + name}
+	if _, ok := g.defs[name]; ok {
+		return ref
+	}
+
+	g.defs[name] = nil // placeholder, for recursive types
+	if t.Implements(schemaerType) {
+		v := reflect.Zero(t).Interface().(jsonSchemaer)
+		g.defs[name] = v.JSONSchema(func(v any) map[string]any { return g.schema(reflect.TypeOf(v)) })
+	} else {
+		g.defs[name] = g.inline(t)
+	}
+	return ref
+}
+
+func (g *schemaGen) inline(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	return map[string]any{}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		// Same keys as yaml.v3.
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch key {
+		case "-":
+			continue
+		case "":
+			key = strings.ToLower(f.Name)
+		}
+		props[key] = g.schema(f.Type)
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestCfgSchema checks that the default configuration, and a configuration
+// using includes, profiles and conditional layouts, validate against the
+// configuration JSON Schema.
+func TestCfgSchema(t *testing.T) {
+	const cfg = `
+include: [common.yml]
+tmux:
+    layout: [branch, " ", {if: dirty, then: [flags], else: [clean]}]
+    options:
+        fetch_interval: 1h30m
+profiles:
+    compact:
+        tmux:
+            options:
+                branch_max_len: 12
+`
+
+	// Round-trip through JSON, to validate against what's actually printed.
+	var buf bytes.Buffer
+	if err := writeCfgSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	for name, doc := range map[string][]byte{"default": cfgBytes, "custom": []byte(cfg)} {
+		t.Run(name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal(doc, &root); err != nil {
+				t.Fatal(err)
+			}
+			v := validator{root: schema, defs: schema["definitions"].(map[string]any)}
+			if err := v.validate(schema, root.Content[0], "config"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	// Invalid configurations must be rejected.
+	for _, doc := range []string{
+		"tmux: {options: {unknown: 1}}",
+		"tmux: {options: {branch_trim: up}}",
+		"tmux: {options: {branch_max_len: ten}}",
+		"tmux: {layout: [{if: never, then: [flags]}]}",
+		"profiles: {p: {tmux: {symbls: {}}}}",
+	} {
+		var root yaml.Node
+		if err := yaml.Unmarshal([]byte(doc), &root); err != nil {
+			t.Fatal(err)
+		}
+		v := validator{root: schema, defs: schema["definitions"].(map[string]any)}
+		if err := v.validate(schema, root.Content[0], "config"); err == nil {
+			t.Errorf("%s: validated against the schema", doc)
+		}
+	}
+}
+
+// validator validates YAML nodes against the subset of JSON Schema used by
+// the configuration schema.
+type validator struct {
+	root map[string]any
+	defs map[string]any
+}
+
+func (v *validator) validate(schema map[string]any, node *yaml.Node, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		if ref == "#" {
+			return v.validate(v.root, node, path)
+		}
+		def := v.defs[strings.TrimPrefix(ref, "#/definitions/")]
+		return v.validate(def.(map[string]any), node, path)
+	}
+
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for _, s := range anyOf {
+			if v.validate(s.(map[string]any), node, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: no matching schema", path)
+	}
+
+	switch schema["type"] {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i].Value, node.Content[i+1]
+			s, ok := props[key].(map[string]any)
+			if !ok {
+				s, ok = schema["additionalProperties"].(map[string]any)
+			}
+			if !ok {
+				return fmt.Errorf("%s: unexpected property %q", path, key)
+			}
+			if err := v.validate(s, val, path+"."+key); err != nil {
+				return err
+			}
+		}
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		for i, item := range node.Content {
+			if err := v.validate(schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s: expected a string", path)
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(node.Value) {
+			return fmt.Errorf("%s: %q doesn't match %s", path, node.Value, pattern)
+		}
+	case "integer":
+		if _, err := strconv.Atoi(node.Value); err != nil || node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s: expected an integer", path)
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(node.Value); err != nil || node.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s: expected a boolean", path)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		var values []string
+		for _, e := range enum {
+			values = append(values, fmt.Sprint(e))
+		}
+		if !slices.Contains(values, node.Value) {
+			return fmt.Errorf("%s: %q isn't one of %q", path, node.Value, values)
+		}
+	}
+	return nil
+}
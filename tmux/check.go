@@ -0,0 +1,212 @@
+package tmux
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A CheckError is a problem found in a configuration.
+type CheckError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// maybeKeyword matches layout strings that look like keywords rather than
+// literal strings.
+var maybeKeyword = regexp.MustCompile(`^[a-z]+(-[a-z]+)*$`)
+
+// maxTypos is the maximum edit distance between a layout string and a
+// keyword for the string to be considered a misspelled keyword.
+const maxTypos = 2
+
+// Check reports the unknown layout keywords and invalid style strings found
+// in node, the YAML mapping node of a tmux configuration section, including in
+// its segments, as well as enabled segments a template hides. Errors are of
+// type *CheckError.
+func Check(node *yaml.Node) []error {
+	var errs []error
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var template, enabled *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "layout":
+			errs = append(errs, checkLayout(val)...)
+		case "styles":
+			errs = append(errs, checkStyles(val)...)
+		case "segments":
+			errs = append(errs, checkSegments(val)...)
+			enabled = MappingValue(val, "enabled")
+		case "template":
+			template = val
+		}
+	}
+
+	var on bool
+	if template != nil && template.Value != "" && enabled != nil && enabled.Decode(&on) == nil && on {
+		errs = append(errs, &CheckError{
+			Line:   enabled.Line,
+			Column: enabled.Column,
+			Msg:    "segments aren't shown since a template is set",
+		})
+	}
+	return errs
+}
+
+// MappingValue returns the value of key in the mapping node, or nil if there's
+// none.
+func MappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func checkLayout(node *yaml.Node) []error {
+	var errs []error
+	if node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	for _, item := range node.Content {
+		switch item.Kind {
+		case yaml.ScalarNode:
+			// Any string is valid, but one close to a keyword is likely a typo.
+			if !maybeKeyword.MatchString(item.Value) || isKeyword(item.Value) {
+				continue
+			}
+			if kw, ok := closestKeyword(item.Value); ok {
+				errs = append(errs, &CheckError{
+					Line:   item.Line,
+					Column: item.Column,
+					Msg:    fmt.Sprintf("unknown layout keyword %q, did you mean %q?", item.Value, kw),
+				})
+			}
+		case yaml.MappingNode:
+			// Conditional block.
+			for i := 0; i+1 < len(item.Content); i += 2 {
+				switch key := item.Content[i]; key.Value {
+				case "if":
+				case "then", "else":
+					errs = append(errs, checkLayout(item.Content[i+1])...)
+				default:
+					errs = append(errs, &CheckError{
+						Line:   key.Line,
+						Column: key.Column,
+						Msg:    fmt.Sprintf("unknown conditional block key %q, expected 'if', 'then' or 'else'", key.Value),
+					})
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func checkStyles(node *yaml.Node) []error {
+	var errs []error
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		if _, err := styleDirectives(val.Value); err != nil {
+			errs = append(errs, &CheckError{
+				Line:   val.Line,
+				Column: val.Column,
+				Msg:    fmt.Sprintf("style %q: %v", key.Value, err),
+			})
+		}
+	}
+	return errs
+}
+
+func checkSegments(node *yaml.Node) []error {
+	var errs []error
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "style":
+			if _, err := styleDirectives(val.Value); err != nil {
+				errs = append(errs, &CheckError{
+					Line:   val.Line,
+					Column: val.Column,
+					Msg:    fmt.Sprintf("segment style: %v", err),
+				})
+			}
+		case "styles":
+			if val.Kind != yaml.MappingNode {
+				continue
+			}
+			for j := 0; j+1 < len(val.Content); j += 2 {
+				kw := val.Content[j]
+				if isKeyword(kw.Value) {
+					continue
+				}
+				msg := fmt.Sprintf("unknown layout keyword %q", kw.Value)
+				if sugg, ok := closestKeyword(kw.Value); ok {
+					msg += fmt.Sprintf(", did you mean %q?", sugg)
+				}
+				errs = append(errs, &CheckError{Line: kw.Line, Column: kw.Column, Msg: msg})
+			}
+			errs = append(errs, checkStyles(val)...)
+		}
+	}
+	return errs
+}
+
+// closestKeyword returns the keyword the closest to s, if it's within maxTypos
+// edits.
+func closestKeyword(s string) (string, bool) {
+	best, bestDist := "", maxTypos+1
+	for _, kw := range keywords {
+		if d := editDistance(s, kw.name); d < bestDist {
+			best, bestDist = kw.name, d
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func isKeyword(s string) bool {
+	_, ok := lookupKeyword(s)
+	return ok
+}
@@ -0,0 +1,103 @@
+package tmux
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arl/gitstatus"
+	"gopkg.in/yaml.v3"
+)
+
+func TestKeywords(t *testing.T) {
+	f := &Formater{st: &gitstatus.Status{}}
+	seen := make(map[string]bool)
+	for _, kw := range keywords {
+		if seen[kw.name] {
+			t.Errorf("%q is listed twice", kw.name)
+		}
+		seen[kw.name] = true
+		if comps, ok := f.components(kw.name); !ok || len(comps) == 0 {
+			t.Errorf("%q is listed as a keyword but has no components", kw.name)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	const cfg = `
+styles:
+    clear: "#[none]"
+    branch: "#[fg=white,bold]#[bg=colour235]"
+    remote: "#[fg=#00ff00 nounderscore]"
+    staged: "#[fg=gren]"
+    stashed: "bold"
+    clean: "#[bg=colour256]"
+layout: [branch, " - ", flag, {if: dirty, then: [flags, "x"], else: [remot]}, {if: clean, thn: [branch]}]
+segments:
+    style: "#[bg=gery]"
+    styles:
+        branch: "#[fg=black,bg=blue]"
+        stat: "#[bg=green]"
+`
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(cfg), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, err := range Check(node.Content[0]) {
+		got = append(got, err.Error())
+	}
+
+	want := []string{
+		`line 6, column 13: style "staged": unknown color "gren"`,
+		`line 7, column 14: style "stashed": expected '#[' at "bold"`,
+		`line 8, column 12: style "clean": invalid color "colour256", expected colour0 to colour255`,
+		`line 9, column 25: unknown layout keyword "flag", did you mean "flags"?`,
+		`line 9, column 70: unknown layout keyword "remot", did you mean "remote"?`,
+		`line 9, column 91: unknown conditional block key "thn", expected 'if', 'then' or 'else'`,
+		`line 11, column 12: segment style: unknown color "gery"`,
+		`line 14, column 9: unknown layout keyword "stat", did you mean "stats"?`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCheckTemplateSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  string
+		want []string
+	}{
+		{
+			name: "segments",
+			cfg:  "segments: {enabled: true}",
+		},
+		{
+			name: "template",
+			cfg:  "template: '{{.LocalBranch}}'\nsegments: {enabled: false}",
+		},
+		{
+			name: "template and segments",
+			cfg:  "template: '{{.LocalBranch}}'\nsegments: {enabled: true}",
+			want: []string{"line 2, column 21: segments aren't shown since a template is set"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.cfg), &node); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, err := range Check(node.Content[0]) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
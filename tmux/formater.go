@@ -3,11 +3,16 @@ package tmux
 import (
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/arl/gitstatus"
 	"gopkg.in/yaml.v3"
+
+	"github.com/arl/gitmux/repo"
 )
 
 // Config is the configuration of the Git status tmux formatter.
@@ -18,9 +23,13 @@ type Config struct {
 	// components.
 	Styles styles
 	// Layout sets the output format of the Git status.
-	Layout []string `yaml:",flow"`
+	Layout layout `yaml:",flow"`
 	// Options contains additional configuration options.
 	Options options
+	// Segments configures the powerline-style output of the layout.
+	Segments segments
+	// Template, if set, is a text/template used instead of Layout.
+	Template string
 }
 
 type symbols struct {
@@ -39,6 +48,29 @@ type symbols struct {
 
 	Insertions string // Insertions is the string shown before the count of inserted lines.
 	Deletions  string // Deletions is the string shown before the count of deleted lines.
+
+	Progress string // Progress is the string shown before the step counter of a rebase or am in progress.
+	Target   string // Target is the string shown before the target of the operation in progress.
+	Worktree string // Worktree is the string shown before the name of a linked worktree.
+
+	SubmoduleUninit    string `yaml:"submodule_uninit"`    // SubmoduleUninit is the string shown before the count of uninitialized submodules.
+	SubmoduleOutOfSync string `yaml:"submodule_outofsync"` // SubmoduleOutOfSync is the string shown before the count of submodules out of sync with the recorded commit.
+	SubmoduleDirty     string `yaml:"submodule_dirty"`     // SubmoduleDirty is the string shown before the count of dirty submodules.
+
+	Repo string // Repo is the string shown before the repository name.
+	Path string // Path is the string shown before the current directory path, relative to the repository.
+
+	CommitSubject string `yaml:"commit_subject"` // CommitSubject is the string shown before the subject of the last commit.
+	CommitAuthor  string `yaml:"commit_author"`  // CommitAuthor is the string shown before the author of the last commit.
+	CommitAge     string `yaml:"commit_age"`     // CommitAge is the string shown before the age of the last commit.
+
+	StaleFetch string `yaml:"stale_fetch"` // StaleFetch is the string shown before the age of the last fetch, when it's too old.
+
+	Base string `yaml:"base"` // Base is the string shown before the name of the base branch.
+	Push string `yaml:"push"` // Push is the string shown before the name of the push branch.
+
+	UpstreamGone string `yaml:"upstream_gone"` // UpstreamGone is the string shown after the upstream branch when it's gone.
+	NoUpstream   string `yaml:"no_upstream"`   // NoUpstream is the string shown instead of the upstream branch when there's none.
 }
 
 type styles struct {
@@ -59,6 +91,29 @@ type styles struct {
 
 	Insertions string // Insertions is the style string printed before the count of inserted lines.
 	Deletions  string // Deletions is the style string printed before the count of deleted lines.
+
+	Progress string // Progress is the style string printed before the step counter of a rebase or am in progress.
+	Target   string // Target is the style string printed before the target of the operation in progress.
+	Worktree string // Worktree is the style string printed before the name of a linked worktree.
+
+	SubmoduleUninit    string `yaml:"submodule_uninit"`    // SubmoduleUninit is the style string printed before the count of uninitialized submodules.
+	SubmoduleOutOfSync string `yaml:"submodule_outofsync"` // SubmoduleOutOfSync is the style string printed before the count of submodules out of sync.
+	SubmoduleDirty     string `yaml:"submodule_dirty"`     // SubmoduleDirty is the style string printed before the count of dirty submodules.
+
+	Repo string // Repo is the style string printed before the repository name.
+	Path string // Path is the style string printed before the current directory path.
+
+	CommitSubject string `yaml:"commit_subject"` // CommitSubject is the style string printed before the subject of the last commit.
+	CommitAuthor  string `yaml:"commit_author"`  // CommitAuthor is the style string printed before the author of the last commit.
+	CommitAge     string `yaml:"commit_age"`     // CommitAge is the style string printed before the age of the last commit.
+
+	StaleFetch string `yaml:"stale_fetch"` // StaleFetch is the style string printed before the age of the last fetch, when it's too old.
+
+	BaseDivergence string `yaml:"base_divergence"` // BaseDivergence is the style string printed before the divergence with the base branch.
+	PushDivergence string `yaml:"push_divergence"` // PushDivergence is the style string printed before the divergence with the push branch.
+
+	UpstreamGone string `yaml:"upstream_gone"` // UpstreamGone is the style string printed before the gone upstream symbol.
+	NoUpstream   string `yaml:"no_upstream"`   // NoUpstream is the style string printed before the no upstream symbol.
 }
 
 const (
@@ -82,7 +137,30 @@ func (d *direction) UnmarshalYAML(value *yaml.Node) error {
 	case dirCenter:
 		*d = dirCenter
 	default:
-		return fmt.Errorf("'direction': unexpected value %v", s)
+		return valueError(value, "'direction': unexpected value %v", s)
+	}
+	return nil
+}
+
+const (
+	repoPathName     repoPath = "name"
+	repoPathHome     repoPath = "home"
+	repoPathAbsolute repoPath = "absolute"
+)
+
+// repoPath defines how the repository is shown.
+type repoPath string
+
+func (p *repoPath) UnmarshalYAML(value *yaml.Node) error {
+	s := ""
+	if err := value.Decode(&s); err != nil {
+		return fmt.Errorf("error decoding 'repo_path': %v", s)
+	}
+	switch repoPath(s) {
+	case repoPathName, repoPathHome, repoPathAbsolute:
+		*p = repoPath(s)
+	default:
+		return valueError(value, "'repo_path': unexpected value %v", s)
 	}
 	return nil
 }
@@ -95,11 +173,27 @@ type options struct {
 	DivergenceSpace   bool      `yaml:"divergence_space"`
 	SwapDivergence    bool      `yaml:"swap_divergence"`
 	FlagsWithoutCount bool      `yaml:"flags_without_count"`
+	HideWorktreeName  bool      `yaml:"hide_worktree_name"`
+	RepoPath          repoPath  `yaml:"repo_path"`
+	PathMaxLen        int       `yaml:"path_max_len"`
+	PathTrim          direction `yaml:"path_trim"`
+
+	CommitSubjectMaxLen int `yaml:"commit_subject_max_len"`
+	CommitAuthorMaxLen  int `yaml:"commit_author_max_len"`
+
+	StaleFetchAfter time.Duration `yaml:"stale_fetch_after"`
+	FetchInterval   time.Duration `yaml:"fetch_interval"`
+
+	BaseBranch string `yaml:"base_branch"`
 }
 
 // A Formater formats git status to a tmux style string.
 type Formater struct {
 	Config
+	// Info holds additional information about the repository, as selected by
+	// Config.Fields. It may be nil.
+	Info *repo.Info
+
 	st *gitstatus.Status
 }
 
@@ -155,6 +249,15 @@ func (f *Formater) Format(w io.Writer, st *gitstatus.Status) error {
 
 	f.st = st
 
+	if f.Template != "" {
+		s, err := f.execTemplate()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s%s%s", f.Styles.Clear, s, resetStyles)
+		return err
+	}
+
 	// Overall working tree state
 	if f.st.IsInitial {
 		branch := truncate(f.st.LocalBranch, f.Options.Ellipsis, f.Options.BranchMaxLen, f.Options.BranchTrim)
@@ -169,7 +272,17 @@ func (f *Formater) Format(w io.Writer, st *gitstatus.Status) error {
 
 const resetStyles = "#[fg=default,bg=default]"
 
+// escapeHash escapes the hashes of s, free text such as a commit subject, so
+// that tmux doesn't interpret them as the start of a format or a style.
+func escapeHash(s string) string {
+	return strings.ReplaceAll(s, "#", "##")
+}
+
 func (f *Formater) format() string {
+	if f.Segments.Enabled {
+		return f.formatSegments()
+	}
+
 	var comps []string
 
 	// Add spacing between non-empty components.
@@ -185,28 +298,18 @@ func (f *Formater) format() string {
 	}
 
 	sb := strings.Builder{}
-	for _, item := range f.Layout {
-		switch item {
-		case "branch":
-			comps = append(comps, f.specialState())
-		case "remote":
-			comps = append(comps, f.remoteBranch())
-			comps = append(comps, f.divergence())
-		case "remote-branch":
-			comps = append(comps, f.remoteBranch())
-		case "divergence":
-			comps = append(comps, f.divergence())
-		case "flags":
-			comps = append(comps, f.flags())
-		case "stats":
-			comps = append(comps, f.stats())
-		default:
-			sb.WriteString(joinComps())
-			sb.WriteString(f.Styles.Clear)
-			sb.WriteString(item)
-			comps = comps[:0]
+
+	f.walkLayout(f.Layout, func(item string) {
+		if c, ok := f.components(item); ok {
+			comps = append(comps, c...)
+			return
 		}
-	}
+
+		sb.WriteString(joinComps())
+		sb.WriteString(f.Styles.Clear)
+		sb.WriteString(item)
+		comps = comps[:0]
+	})
 
 	sb.WriteString(joinComps())
 
@@ -216,16 +319,131 @@ func (f *Formater) format() string {
 	return sb.String()
 }
 
+// walkLayout calls fn for each keyword or string of items, in order, expanding
+// conditional blocks in place.
+func (f *Formater) walkLayout(items layout, fn func(item string)) {
+	for _, item := range items {
+		if cond, ok := item.(*conditional); ok {
+			if cond.If.eval(f.st) {
+				f.walkLayout(cond.Then, fn)
+			} else {
+				f.walkLayout(cond.Else, fn)
+			}
+			continue
+		}
+
+		item, _ := item.(string)
+		fn(item)
+	}
+}
+
+// A keyword is a layout keyword.
+type keyword struct {
+	name       string
+	components []func(*Formater) string // components are the components shown for the keyword.
+	fields     repo.Fields              // fields is the repository information the components require.
+}
+
+// keywords lists the layout keywords.
+var keywords = []keyword{
+	{"branch", []func(*Formater) string{(*Formater).specialState}, repo.Progress},
+	{"remote", []func(*Formater) string{(*Formater).remoteBranch, (*Formater).divergence}, repo.Upstream},
+	{"remote-branch", []func(*Formater) string{(*Formater).remoteBranch}, repo.Upstream},
+	{"divergence", []func(*Formater) string{(*Formater).divergence}, 0},
+	{"flags", []func(*Formater) string{(*Formater).flags}, 0},
+	{"stats", []func(*Formater) string{(*Formater).stats}, 0},
+	{"operation-target", []func(*Formater) string{(*Formater).operationTarget}, repo.Target},
+	{"worktree", []func(*Formater) string{(*Formater).worktree}, repo.Worktree},
+	{"submodules", []func(*Formater) string{(*Formater).submodules}, repo.Submodules},
+	{"repo", []func(*Formater) string{(*Formater).repo}, repo.Toplevel},
+	{"path", []func(*Formater) string{(*Formater).path}, repo.Subdir},
+	{"commit-subject", []func(*Formater) string{(*Formater).commitSubject}, repo.Commit},
+	{"commit-author", []func(*Formater) string{(*Formater).commitAuthor}, repo.Commit},
+	{"commit-age", []func(*Formater) string{(*Formater).commitAge}, repo.Commit},
+	{"stale-fetch", []func(*Formater) string{(*Formater).staleFetch}, repo.LastFetch},
+	{"base-divergence", []func(*Formater) string{(*Formater).baseDivergence}, repo.Base},
+	{"push-divergence", []func(*Formater) string{(*Formater).pushDivergence}, repo.Push},
+}
+
+// lookupKeyword returns the layout keyword named s, or false if s is not a
+// keyword.
+func lookupKeyword(s string) (keyword, bool) {
+	for _, kw := range keywords {
+		if kw.name == s {
+			return kw, true
+		}
+	}
+	return keyword{}, false
+}
+
+// Fields returns the repository information required to format the Git status
+// with this configuration.
+func (c *Config) Fields() repo.Fields {
+	if c.Template != "" {
+		return c.templateFields()
+	}
+
+	var fields repo.Fields
+	var walk func(items layout)
+	walk = func(items layout) {
+		for _, item := range items {
+			switch item := item.(type) {
+			case *conditional:
+				walk(item.Then)
+				walk(item.Else)
+			case string:
+				fields |= c.keywordFields(item)
+			}
+		}
+	}
+	walk(c.Layout)
+	return fields
+}
+
+// keywordFields returns the repository information required by the layout
+// keyword kw with this configuration.
+func (c *Config) keywordFields(kw string) repo.Fields {
+	k, _ := lookupKeyword(kw)
+	fields := k.fields
+	if c.Symbols.UpstreamGone == "" {
+		// Telling whether the upstream branch is gone costs a Git command,
+		// not worth it if it isn't shown.
+		fields &^= repo.Upstream
+	}
+	return fields
+}
+
+// RepoOptions returns how to retrieve the repository information with this
+// configuration.
+func (c *Config) RepoOptions() repo.Options {
+	return repo.Options{BaseBranch: c.Options.BaseBranch}
+}
+
+// components returns the components shown for the layout keyword kw, or false
+// if kw is not a keyword.
+func (f *Formater) components(kw string) ([]string, bool) {
+	k, ok := lookupKeyword(kw)
+	if !ok {
+		return nil, false
+	}
+
+	comps := make([]string, len(k.components))
+	for i, comp := range k.components {
+		comps[i] = comp(f)
+	}
+	return comps, true
+}
+
 func (f *Formater) specialState() string {
 	s := f.Styles.Clear
 
 	switch f.st.State {
 	case gitstatus.Rebasing:
-		s += fmt.Sprintf("%s[rebase] ", f.Styles.State)
+		s += fmt.Sprintf("%s[rebase%s] ", f.Styles.State, f.progress())
 	case gitstatus.AM:
-		s += fmt.Sprintf("%s[am] ", f.Styles.State)
+		s += fmt.Sprintf("%s[am%s] ", f.Styles.State, f.progress())
 	case gitstatus.AMRebase:
-		s += fmt.Sprintf("%s[am-rebase] ", f.Styles.State)
+		s += fmt.Sprintf("%s[am-rebase%s] ", f.Styles.State, f.progress())
 	case gitstatus.Merging:
 		s += fmt.Sprintf("%s[merge] ", f.Styles.State)
 	case gitstatus.CherryPicking:
@@ -242,15 +460,193 @@ func (f *Formater) specialState() string {
 	return s
 }
 
+// progress returns the step counter of the rebase or am in progress, if known.
+func (f *Formater) progress() string {
+	if f.Info == nil || f.Info.Total == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s%s%d/%d%s", f.Styles.Progress, f.Symbols.Progress, f.Info.Step, f.Info.Total, f.Styles.State)
+}
+
+// operationTarget returns the branch or commit being rebased onto, merged,
+// cherry-picked or reverted, if any.
+func (f *Formater) operationTarget() string {
+	if f.Info == nil || f.Info.Target == "" {
+		return ""
+	}
+
+	target := truncate(f.Info.Target, f.Options.Ellipsis, f.Options.BranchMaxLen, f.Options.BranchTrim)
+	return fmt.Sprintf("%s%s%s%s", f.Styles.Clear, f.Styles.Target, f.Symbols.Target, target)
+}
+
+// worktree returns the name of the linked worktree, or just the worktree symbol
+// if hide_worktree_name is set. It returns an empty string in the main worktree.
+func (f *Formater) worktree() string {
+	if f.Info == nil || f.Info.Worktree == "" {
+		return ""
+	}
+
+	name := f.Info.Worktree
+	if f.Options.HideWorktreeName {
+		name = ""
+	}
+	return fmt.Sprintf("%s%s%s%s", f.Styles.Clear, f.Styles.Worktree, f.Symbols.Worktree, name)
+}
+
+// repo returns the repository name, or the path of its top-level directory,
+// depending on the repo_path option.
+func (f *Formater) repo() string {
+	if f.Info == nil || f.Info.Toplevel == "" {
+		return ""
+	}
+
+	path := f.Info.Toplevel
+	switch f.Options.RepoPath {
+	case repoPathAbsolute:
+	case repoPathHome:
+		path = relHome(path)
+	default:
+		path = filepath.Base(path)
+	}
+	return fmt.Sprintf("%s%s%s%s", f.Styles.Clear, f.Styles.Repo, f.Symbols.Repo, path)
+}
+
+// path returns the path of the current directory relative to the top-level
+// directory of the repository, empty in the top-level directory.
+func (f *Formater) path() string {
+	if f.Info == nil || f.Info.Subdir == "" {
+		return ""
+	}
+
+	path := truncate(f.Info.Subdir, f.Options.Ellipsis, f.Options.PathMaxLen, f.Options.PathTrim)
+	return fmt.Sprintf("%s%s%s%s", f.Styles.Clear, f.Styles.Path, f.Symbols.Path, path)
+}
+
+func (f *Formater) commitSubject() string {
+	if f.Info == nil || f.Info.Commit.Subject == "" {
+		return ""
+	}
+
+	subject := escapeHash(truncate(f.Info.Commit.Subject, f.Options.Ellipsis, f.Options.CommitSubjectMaxLen, dirRight))
+	return fmt.Sprintf("%s%s%s%s", f.Styles.Clear, f.Styles.CommitSubject, f.Symbols.CommitSubject, subject)
+}
+
+func (f *Formater) commitAuthor() string {
+	if f.Info == nil || f.Info.Commit.Author == "" {
+		return ""
+	}
+
+	author := escapeHash(truncate(f.Info.Commit.Author, f.Options.Ellipsis, f.Options.CommitAuthorMaxLen, dirRight))
+	return fmt.Sprintf("%s%s%s%s", f.Styles.Clear, f.Styles.CommitAuthor, f.Symbols.CommitAuthor, author)
+}
+
+func (f *Formater) commitAge() string {
+	if f.Info == nil || f.Info.Commit.Time.IsZero() {
+		return ""
+	}
+
+	age := relativeTime(timeNow().Sub(f.Info.Commit.Time))
+	return fmt.Sprintf("%s%s%s%s", f.Styles.Clear, f.Styles.CommitAge, f.Symbols.CommitAge, age)
+}
+
+// staleFetch returns the age of the last fetch if it's older than the
+// stale_fetch_after option, since divergence counts might then be outdated.
+// It returns an empty string if the branch has no upstream, or if the
+// repository has never been fetched.
+func (f *Formater) staleFetch() string {
+	if f.Info == nil || f.st.RemoteBranch == "" {
+		return ""
+	}
+
+	// Never fetched is the stalest it gets, whatever stale_fetch_after.
+	if f.Info.NeverFetched {
+		return fmt.Sprintf("%s%s%s", f.Styles.Clear, f.Styles.StaleFetch, f.Symbols.StaleFetch)
+	}
+	if f.Info.LastFetch.IsZero() {
+		return ""
+	}
+
+	age := timeNow().Sub(f.Info.LastFetch)
+	if age < f.Options.StaleFetchAfter {
+		return ""
+	}
+	return fmt.Sprintf("%s%s%s%s", f.Styles.Clear, f.Styles.StaleFetch, f.Symbols.StaleFetch, relativeTime(age))
+}
+
+// timeNow returns the current time. It's a variable so tests can override it.
+var timeNow = time.Now
+
+// relativeTime returns a short representation of d, in the largest unit
+// possible, for example 3h.
+func relativeTime(d time.Duration) string {
+	const (
+		day   = 24 * time.Hour
+		week  = 7 * day
+		month = 30 * day
+		year  = 365 * day
+	)
+
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", max(0, int(d/time.Second)))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d < day:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d < week:
+		return fmt.Sprintf("%dd", d/day)
+	case d < month:
+		return fmt.Sprintf("%dw", d/week)
+	case d < year:
+		return fmt.Sprintf("%dmo", d/month)
+	}
+	return fmt.Sprintf("%dy", d/year)
+}
+
+// relHome returns path, relative to the user home directory and prefixed with
+// '~', if it's in it.
+func relHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	// Git resolves symbolic links, the home directory may contain some.
+	homes := []string{home}
+	if resolved, err := filepath.EvalSymlinks(home); err == nil && resolved != home {
+		homes = append(homes, resolved)
+	}
+
+	for _, home := range homes {
+		rel, err := filepath.Rel(home, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel == "." {
+			return "~"
+		}
+		return filepath.Join("~", rel)
+	}
+	return path
+}
+
 func (f *Formater) remoteBranch() string {
 	if f.st.RemoteBranch == "" {
-		return ""
+		if f.st.IsDetached || f.Symbols.NoUpstream == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s%s%s", f.Styles.Clear, f.Styles.NoUpstream, f.Symbols.NoUpstream)
 	}
 
 	s := f.Styles.Clear
 
 	branch := truncate(f.st.RemoteBranch, f.Options.Ellipsis, f.Options.BranchMaxLen, f.Options.BranchTrim)
 	s += fmt.Sprintf("%s%s", f.Styles.Remote, branch)
+
+	if f.Info != nil && f.Info.UpstreamGone && f.Symbols.UpstreamGone != "" {
+		s += fmt.Sprintf(" %s%s%s", f.Styles.Clear, f.Styles.UpstreamGone, f.Symbols.UpstreamGone)
+	}
 	return s
 }
 
@@ -259,15 +655,50 @@ func (f *Formater) divergence() string {
 		return ""
 	}
 
+	return f.Styles.Clear + f.Styles.Divergence + f.counts(f.st.AheadCount, f.st.BehindCount)
+}
+
+// baseDivergence returns the name of the base branch followed by the ahead
+// and behind counts of HEAD relative to it, if they diverge.
+func (f *Formater) baseDivergence() string {
+	if f.Info == nil || f.Info.Base.Ref == "" {
+		return ""
+	}
+
+	base := f.Info.Base
+	if base.Ahead == 0 && base.Behind == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s%s%s%s %s", f.Styles.Clear, f.Styles.BaseDivergence, f.Symbols.Base, base.Ref, f.counts(base.Ahead, base.Behind))
+}
+
+// pushDivergence returns the name of the push branch followed by the ahead
+// and behind counts of HEAD relative to it, if they diverge.
+func (f *Formater) pushDivergence() string {
+	if f.Info == nil || f.Info.Push.Ref == "" {
+		return ""
+	}
+
+	push := f.Info.Push
+	if push.Ahead == 0 && push.Behind == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s%s%s%s %s", f.Styles.Clear, f.Styles.PushDivergence, f.Symbols.Push, push.Ref, f.counts(push.Ahead, push.Behind))
+}
+
+// counts returns the ahead and behind counts of a divergence, with their
+// symbols.
+func (f *Formater) counts(aheadCount, behindCount int) string {
 	behind := ""
 	ahead := ""
-	s := f.Styles.Clear + f.Styles.Divergence
-	if f.st.BehindCount != 0 {
-		behind = fmt.Sprintf("%s%d", f.Symbols.Behind, f.st.BehindCount)
+	if behindCount != 0 {
+		behind = fmt.Sprintf("%s%d", f.Symbols.Behind, behindCount)
 	}
 
-	if f.st.AheadCount != 0 {
-		ahead = fmt.Sprintf("%s%d", f.Symbols.Ahead, f.st.AheadCount)
+	if aheadCount != 0 {
+		ahead = fmt.Sprintf("%s%d", f.Symbols.Ahead, aheadCount)
 	}
 
 	// Handle 'swap divergence'
@@ -283,7 +714,7 @@ func (f *Formater) divergence() string {
 	if f.Options.DivergenceSpace && right != "" && left != "" {
 		space = " "
 	}
-	return s + left + space + right
+	return left + space + right
 }
 
 func (f *Formater) currentRef() string {
@@ -300,7 +731,7 @@ func (f *Formater) appendFlag(flags []string, style, symbol string, count int) [
 	if count == 0 {
 		return flags
 	}
-	
+
 	if f.Options.FlagsWithoutCount {
 		// When flags_without_count is true, show symbol only (empty string if symbol is empty)
 		if symbol == "" {
@@ -308,7 +739,7 @@ func (f *Formater) appendFlag(flags []string, style, symbol string, count int) [
 		}
 		return append(flags, fmt.Sprintf("%s%s", style, symbol))
 	}
-	
+
 	// When flags_without_count is false, show symbol + count, or just count if symbol is empty
 	return append(flags, fmt.Sprintf("%s%s%d", style, symbol, count))
 }
@@ -343,6 +774,24 @@ func (f *Formater) flags() string {
 	return ""
 }
 
+func (f *Formater) submodules() string {
+	if f.Info == nil {
+		return ""
+	}
+
+	var flags []string
+	sum := f.Info.Submodules
+	flags = f.appendFlag(flags, f.Styles.SubmoduleUninit, f.Symbols.SubmoduleUninit, sum.Uninitialized)
+	flags = f.appendFlag(flags, f.Styles.SubmoduleOutOfSync, f.Symbols.SubmoduleOutOfSync, sum.OutOfSync)
+	flags = f.appendFlag(flags, f.Styles.SubmoduleDirty, f.Symbols.SubmoduleDirty, sum.Dirty)
+
+	if len(flags) == 0 {
+		return ""
+	}
+
+	return f.Styles.Clear + strings.Join(flags, " ")
+}
+
 func (f *Formater) stats() string {
 	stats := make([]string, 0, 2)
 
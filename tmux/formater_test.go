@@ -2,9 +2,13 @@ package tmux
 
 import (
 	"io"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/arl/gitstatus"
+
+	"github.com/arl/gitmux/repo"
 )
 
 func TestFlags(t *testing.T) {
@@ -12,7 +16,7 @@ func TestFlags(t *testing.T) {
 		name    string
 		styles  styles
 		symbols symbols
-		layout  []string
+		layout  layout
 		st      *gitstatus.Status
 		want    string
 	}{
@@ -25,7 +29,7 @@ func TestFlags(t *testing.T) {
 			symbols: symbols{
 				Clean: "[symbol:clean]",
 			},
-			layout: []string{"branch", "..", "remote", "- ", "flags"},
+			layout: layout{"branch", "..", "remote", "- ", "flags"},
 			st: &gitstatus.Status{
 				IsClean: true,
 			},
@@ -42,7 +46,7 @@ func TestFlags(t *testing.T) {
 				Clean:   "[symbol:clean]",
 				Stashed: "[symbol:stashed]",
 			},
-			layout: []string{"branch", "..", "remote", " - ", "flags"},
+			layout: layout{"branch", "..", "remote", " - ", "flags"},
 			st: &gitstatus.Status{
 				IsClean:    true,
 				NumStashed: 1,
@@ -62,7 +66,7 @@ func TestFlags(t *testing.T) {
 				Stashed:  "[symbol:stashed]",
 				Staged:   "[symbol:staged]",
 			},
-			layout: []string{"branch", "..", "remote", "- ", "flags"},
+			layout: layout{"branch", "..", "remote", "- ", "flags"},
 			st: &gitstatus.Status{
 				NumStashed: 1,
 				Porcelain: gitstatus.Porcelain{
@@ -886,7 +890,7 @@ func TestFormat(t *testing.T) {
 		name    string
 		styles  styles
 		symbols symbols
-		layout  []string
+		layout  layout
 		options options
 		st      *gitstatus.Status
 		want    string
@@ -905,7 +909,7 @@ func TestFormat(t *testing.T) {
 				Clean:    "[symbol:clean]",
 				Modified: "[symbol:mod]",
 			},
-			layout: []string{"branch", " .. ", "remote", " - ", "flags"},
+			layout: layout{"branch", " .. ", "remote", " - ", "flags"},
 			st: &gitstatus.Status{
 				Porcelain: gitstatus.Porcelain{
 					LocalBranch:  "Local",
@@ -934,7 +938,7 @@ func TestFormat(t *testing.T) {
 				Ahead:    "[symbol:ahead]",
 				Modified: "[symbol:mod]",
 			},
-			layout: []string{"branch", "~~", "flags"},
+			layout: layout{"branch", "~~", "flags"},
 			st: &gitstatus.Status{
 				Porcelain: gitstatus.Porcelain{
 					LocalBranch:  "Local",
@@ -960,7 +964,7 @@ func TestFormat(t *testing.T) {
 				Branch: "[symbol:branch]",
 				Ahead:  "[symbol:ahead]",
 			},
-			layout: []string{"remote"},
+			layout: layout{"remote"},
 			st: &gitstatus.Status{
 				Porcelain: gitstatus.Porcelain{
 					LocalBranch:  "Local",
@@ -983,7 +987,7 @@ func TestFormat(t *testing.T) {
 				Branch:   "[symbol:branch]",
 				Modified: "[symbol:mod]",
 			},
-			layout: []string{},
+			layout: layout{},
 			st: &gitstatus.Status{
 				Porcelain: gitstatus.Porcelain{
 					LocalBranch: "Local",
@@ -1002,7 +1006,7 @@ func TestFormat(t *testing.T) {
 			symbols: symbols{
 				Branch: "[symbol:branch]",
 			},
-			layout: []string{"branch", "/", "remote"},
+			layout: layout{"branch", "/", "remote"},
 			options: options{
 				BranchMaxLen: 9,
 				BranchTrim:   dirRight,
@@ -1030,7 +1034,7 @@ func TestFormat(t *testing.T) {
 			symbols: symbols{
 				Branch: "[symbol:branch]",
 			},
-			layout: []string{"branch", "remote"},
+			layout: layout{"branch", "remote"},
 			options: options{
 				BranchMaxLen: 9,
 				BranchTrim:   dirLeft,
@@ -1056,7 +1060,7 @@ func TestFormat(t *testing.T) {
 			symbols: symbols{
 				Branch: "[symbol:branch]",
 			},
-			layout: []string{"branch"},
+			layout: layout{"branch"},
 			st: &gitstatus.Status{
 				Porcelain: gitstatus.Porcelain{
 					LocalBranch: "branchName",
@@ -1075,7 +1079,7 @@ func TestFormat(t *testing.T) {
 			symbols: symbols{
 				Clean: "[symbol:clean]",
 			},
-			layout: []string{"flags"},
+			layout: layout{"flags"},
 			st: &gitstatus.Status{
 				IsClean: true,
 			},
@@ -1093,7 +1097,7 @@ func TestFormat(t *testing.T) {
 			symbols: symbols{
 				Clean: "[symbol:clean]",
 			},
-			layout: []string{"flags"},
+			layout: layout{"flags"},
 			st: &gitstatus.Status{
 				IsClean: true,
 			},
@@ -1122,7 +1126,7 @@ func TestFormat(t *testing.T) {
 func Test_stats(t *testing.T) {
 	tests := []struct {
 		name                  string
-		layout                []string
+		layout                layout
 		insertions, deletions int
 		want                  string
 	}{
@@ -1160,7 +1164,7 @@ func Test_stats(t *testing.T) {
 						Deletions:  "[symbol:deletions]",
 						Insertions: "[symbol:insertions]",
 					},
-					Layout: []string{"stats"},
+					Layout: layout{"stats"},
 				},
 				st: &gitstatus.Status{
 					Insertions: tt.insertions,
@@ -1439,3 +1443,835 @@ func compareStrings(t *testing.T, want, got string) {
 %q`, got, want)
 	}
 }
+
+func TestSpecialStateProgress(t *testing.T) {
+	tests := []struct {
+		name  string
+		state gitstatus.TreeState
+		info  *repo.Info
+		want  string
+	}{
+		{
+			name:  "rebase without info",
+			state: gitstatus.Rebasing,
+			want:  "[style:clear][style:state][rebase] ",
+		},
+		{
+			name:  "rebase with unknown progress",
+			state: gitstatus.Rebasing,
+			info:  &repo.Info{},
+			want:  "[style:clear][style:state][rebase] ",
+		},
+		{
+			name:  "rebase",
+			state: gitstatus.Rebasing,
+			info:  &repo.Info{Step: 3, Total: 7},
+			want:  "[style:clear][style:state][rebase[style:progress][symbol:progress]3/7[style:state]] ",
+		},
+		{
+			name:  "am",
+			state: gitstatus.AM,
+			info:  &repo.Info{Step: 1, Total: 2},
+			want:  "[style:clear][style:state][am[style:progress][symbol:progress]1/2[style:state]] ",
+		},
+		{
+			name:  "am-rebase",
+			state: gitstatus.AMRebase,
+			info:  &repo.Info{Step: 2, Total: 2},
+			want:  "[style:clear][style:state][am-rebase[style:progress][symbol:progress]2/2[style:state]] ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles: styles{
+						Clear:    "[style:clear]",
+						State:    "[style:state]",
+						Progress: "[style:progress]",
+					},
+					Symbols: symbols{
+						Progress: "[symbol:progress]",
+					},
+				},
+				Info: tt.info,
+				st: &gitstatus.Status{
+					Porcelain: gitstatus.Porcelain{IsDetached: true},
+					HEAD:      "abcdef",
+					State:     tt.state,
+				},
+			}
+
+			compareStrings(t, tt.want+"[style:clear]abcdef", f.specialState())
+		})
+	}
+}
+
+func TestConfigFields(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want repo.Fields
+	}{
+		{
+			name: "no keywords",
+			cfg:  Config{Layout: layout{"foo", "flags"}},
+			want: 0,
+		},
+		{
+			name: "branch",
+			cfg:  Config{Layout: layout{"flags", "branch"}},
+			want: repo.Progress,
+		},
+		{
+			name: "keyword in conditional",
+			cfg:  Config{Layout: layout{&conditional{If: condDirty, Else: layout{"branch"}}}},
+			want: repo.Progress,
+		},
+		{
+			name: "branch and operation-target",
+			cfg:  Config{Layout: layout{"branch", " ", "operation-target"}},
+			want: repo.Progress | repo.Target,
+		},
+		{
+			name: "remote without upstream_gone",
+			cfg:  Config{Layout: layout{"remote"}},
+			want: 0,
+		},
+		{
+			name: "remote with upstream_gone",
+			cfg:  Config{Layout: layout{"remote"}, Symbols: symbols{UpstreamGone: "✗gone"}},
+			want: repo.Upstream,
+		},
+		{
+			name: "template with upstream gone",
+			cfg:  Config{Template: `{{component "remote-branch"}}{{if .UpstreamGone}}!{{end}}`},
+			want: repo.Upstream,
+		},
+		{
+			name: "template with status fields",
+			cfg:  Config{Template: "{{.LocalBranch}}"},
+			want: 0,
+		},
+		{
+			name: "template with info fields",
+			cfg:  Config{Template: `{{.Worktree}}{{if .Base.Ahead}}{{$.Commit.Subject}}{{end}}{{with .Push}}{{.Ref}}{{end}}`},
+			want: repo.Worktree | repo.Base | repo.Commit | repo.Push,
+		},
+		{
+			name: "template with qualified fields",
+			cfg:  Config{Template: `{{.Info.Commit.Subject}}{{.Status.LocalBranch}}{{$.Info.Subdir}}`},
+			want: repo.Commit | repo.Subdir,
+		},
+		{
+			name: "template with whole info",
+			cfg:  Config{Template: `{{with .Info}}{{.Worktree}}{{end}}`},
+			want: repo.All,
+		},
+		{
+			name: "template with component",
+			cfg:  Config{Template: `{{component "branch"}} {{truncate 10 (component "repo")}}`},
+			want: repo.Progress | repo.Toplevel,
+		},
+		{
+			name: "template with computed component",
+			cfg:  Config{Template: `{{"branch" | component}}`},
+			want: repo.All,
+		},
+		{
+			name: "template with dot",
+			cfg:  Config{Template: `{{printf "%v" .}}`},
+			want: repo.All,
+		},
+		{
+			name: "invalid template",
+			cfg:  Config{Template: `{{.LocalBranch`},
+			want: repo.All,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.Fields(); got != tt.want {
+				t.Errorf("Fields() = %b, want %b", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOperationTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    *repo.Info
+		options options
+		want    string
+	}{
+		{
+			name: "no info",
+			want: "",
+		},
+		{
+			name: "no operation",
+			info: &repo.Info{},
+			want: "",
+		},
+		{
+			name: "target",
+			info: &repo.Info{Target: "main"},
+			want: "[style:clear][style:target][symbol:target]main",
+		},
+		{
+			name:    "truncated target",
+			info:    &repo.Info{Target: "origin/feature"},
+			options: options{BranchMaxLen: 8, BranchTrim: dirLeft, Ellipsis: "…"},
+			want:    "[style:clear][style:target][symbol:target]…feature",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles:  styles{Clear: "[style:clear]", Target: "[style:target]"},
+					Symbols: symbols{Target: "[symbol:target]"},
+					Options: tt.options,
+				},
+				Info: tt.info,
+				st:   &gitstatus.Status{State: gitstatus.Merging},
+			}
+
+			compareStrings(t, tt.want, f.operationTarget())
+		})
+	}
+}
+
+func TestWorktree(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    *repo.Info
+		options options
+		want    string
+	}{
+		{
+			name: "no info",
+			want: "",
+		},
+		{
+			name: "main worktree",
+			info: &repo.Info{},
+			want: "",
+		},
+		{
+			name: "linked worktree",
+			info: &repo.Info{Worktree: "hotfix"},
+			want: "[style:clear][style:worktree][symbol:worktree]hotfix",
+		},
+		{
+			name:    "hide worktree name",
+			info:    &repo.Info{Worktree: "hotfix"},
+			options: options{HideWorktreeName: true},
+			want:    "[style:clear][style:worktree][symbol:worktree]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles:  styles{Clear: "[style:clear]", Worktree: "[style:worktree]"},
+					Symbols: symbols{Worktree: "[symbol:worktree]"},
+					Options: tt.options,
+				},
+				Info: tt.info,
+				st:   &gitstatus.Status{},
+			}
+
+			compareStrings(t, tt.want, f.worktree())
+		})
+	}
+}
+
+func TestSubmodules(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    *repo.Info
+		options options
+		want    string
+	}{
+		{
+			name: "no info",
+			want: "",
+		},
+		{
+			name: "no submodules",
+			info: &repo.Info{},
+			want: "",
+		},
+		{
+			name: "all states",
+			info: &repo.Info{Submodules: repo.SubmodulesSummary{Uninitialized: 1, OutOfSync: 2, Dirty: 3}},
+			want: "[style:clear]" +
+				"[style:uninit][symbol:uninit]1 " +
+				"[style:outofsync][symbol:outofsync]2 " +
+				"[style:dirty][symbol:dirty]3",
+		},
+		{
+			name: "dirty only",
+			info: &repo.Info{Submodules: repo.SubmodulesSummary{Dirty: 1}},
+			want: "[style:clear][style:dirty][symbol:dirty]1",
+		},
+		{
+			name:    "without count",
+			info:    &repo.Info{Submodules: repo.SubmodulesSummary{Uninitialized: 1, OutOfSync: 2}},
+			options: options{FlagsWithoutCount: true},
+			want:    "[style:clear][style:uninit][symbol:uninit] [style:outofsync][symbol:outofsync]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles: styles{
+						Clear:              "[style:clear]",
+						SubmoduleUninit:    "[style:uninit]",
+						SubmoduleOutOfSync: "[style:outofsync]",
+						SubmoduleDirty:     "[style:dirty]",
+					},
+					Symbols: symbols{
+						SubmoduleUninit:    "[symbol:uninit]",
+						SubmoduleOutOfSync: "[symbol:outofsync]",
+						SubmoduleDirty:     "[symbol:dirty]",
+					},
+					Options: tt.options,
+				},
+				Info: tt.info,
+				st:   &gitstatus.Status{},
+			}
+
+			compareStrings(t, tt.want, f.submodules())
+		})
+	}
+}
+
+func TestRepo(t *testing.T) {
+	home := filepath.FromSlash("/home/user")
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name     string
+		toplevel string
+		repoPath repoPath
+		want     string
+	}{
+		{
+			name: "no toplevel",
+			want: "",
+		},
+		{
+			name:     "default",
+			toplevel: filepath.Join(home, "src", "proj"),
+			want:     "[style:clear][style:repo][symbol:repo]proj",
+		},
+		{
+			name:     "name",
+			toplevel: filepath.Join(home, "src", "proj"),
+			repoPath: repoPathName,
+			want:     "[style:clear][style:repo][symbol:repo]proj",
+		},
+		{
+			name:     "home",
+			toplevel: filepath.Join(home, "src", "proj"),
+			repoPath: repoPathHome,
+			want:     "[style:clear][style:repo][symbol:repo]" + filepath.Join("~", "src", "proj"),
+		},
+		{
+			name:     "home, outside of home",
+			toplevel: filepath.FromSlash("/home/username/proj"),
+			repoPath: repoPathHome,
+			want:     "[style:clear][style:repo][symbol:repo]" + filepath.FromSlash("/home/username/proj"),
+		},
+		{
+			name:     "absolute",
+			toplevel: filepath.Join(home, "src", "proj"),
+			repoPath: repoPathAbsolute,
+			want:     "[style:clear][style:repo][symbol:repo]" + filepath.Join(home, "src", "proj"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles:  styles{Clear: "[style:clear]", Repo: "[style:repo]"},
+					Symbols: symbols{Repo: "[symbol:repo]"},
+					Options: options{RepoPath: tt.repoPath},
+				},
+				Info: &repo.Info{Toplevel: tt.toplevel},
+				st:   &gitstatus.Status{},
+			}
+
+			compareStrings(t, tt.want, f.repo())
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		subdir  string
+		options options
+		want    string
+	}{
+		{
+			name: "top-level directory",
+			want: "",
+		},
+		{
+			name:   "subdirectory",
+			subdir: "services/api",
+			want:   "[style:clear][style:path][symbol:path]services/api",
+		},
+		{
+			name:    "truncated left",
+			subdir:  "services/api/v2",
+			options: options{PathMaxLen: 7, PathTrim: dirLeft, Ellipsis: "…"},
+			want:    "[style:clear][style:path][symbol:path]…api/v2",
+		},
+		{
+			name:    "truncated center",
+			subdir:  "services/api/v2",
+			options: options{PathMaxLen: 7, PathTrim: dirCenter, Ellipsis: "…"},
+			want:    "[style:clear][style:path][symbol:path]ser…/v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles:  styles{Clear: "[style:clear]", Path: "[style:path]"},
+					Symbols: symbols{Path: "[symbol:path]"},
+					Options: tt.options,
+				},
+				Info: &repo.Info{Subdir: tt.subdir},
+				st:   &gitstatus.Status{},
+			}
+
+			compareStrings(t, tt.want, f.path())
+		})
+	}
+}
+
+func Test_relativeTime(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{-time.Second, "0s"},
+		{0, "0s"},
+		{59 * time.Second, "59s"},
+		{time.Minute, "1m"},
+		{59*time.Minute + 59*time.Second, "59m"},
+		{3 * time.Hour, "3h"},
+		{47 * time.Hour, "1d"},
+		{6 * 24 * time.Hour, "6d"},
+		{15 * 24 * time.Hour, "2w"},
+		{65 * 24 * time.Hour, "2mo"},
+		{800 * 24 * time.Hour, "2y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			compareStrings(t, tt.want, relativeTime(tt.d))
+		})
+	}
+}
+
+func TestCommit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+
+	tests := []struct {
+		name    string
+		layout  layout
+		commit  repo.CommitInfo
+		options options
+		want    string
+	}{
+		{
+			name:   "no commit",
+			layout: layout{"commit-subject", "commit-author", "commit-age"},
+			want:   resetStyles,
+		},
+		{
+			name:   "all",
+			layout: layout{"commit-subject", "commit-author", "commit-age"},
+			commit: repo.CommitInfo{
+				Subject: "Fix typo",
+				Author:  "Jane Doe",
+				Time:    now.Add(-3*time.Hour - 10*time.Minute),
+			},
+			want: "[style:clear][style:subject][symbol:subject]Fix typo " +
+				"[style:clear][style:author][symbol:author]Jane Doe " +
+				"[style:clear][style:age][symbol:age]3h" +
+				resetStyles,
+		},
+		{
+			name:   "truncated",
+			layout: layout{"commit-subject", "commit-author"},
+			commit: repo.CommitInfo{
+				Subject: "Fix a very long typo",
+				Author:  "Jane Doe",
+				Time:    now,
+			},
+			options: options{CommitSubjectMaxLen: 10, CommitAuthorMaxLen: 4, Ellipsis: "…"},
+			want: "[style:clear][style:subject][symbol:subject]Fix a ver… " +
+				"[style:clear][style:author][symbol:author]Jan…" +
+				resetStyles,
+		},
+		{
+			name:   "hashes",
+			layout: layout{"commit-subject", "commit-author"},
+			commit: repo.CommitInfo{
+				Subject: "Fix #12 #[fg=red]#{pane_id}",
+				Author:  "#1",
+				Time:    now,
+			},
+			want: "[style:clear][style:subject][symbol:subject]Fix ##12 ##[fg=red]##{pane_id} " +
+				"[style:clear][style:author][symbol:author]##1" +
+				resetStyles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles: styles{
+						Clear:         "[style:clear]",
+						CommitSubject: "[style:subject]",
+						CommitAuthor:  "[style:author]",
+						CommitAge:     "[style:age]",
+					},
+					Symbols: symbols{
+						CommitSubject: "[symbol:subject]",
+						CommitAuthor:  "[symbol:author]",
+						CommitAge:     "[symbol:age]",
+					},
+					Layout:  tt.layout,
+					Options: tt.options,
+				},
+				Info: &repo.Info{Commit: tt.commit},
+				st:   &gitstatus.Status{},
+			}
+
+			compareStrings(t, tt.want, f.format())
+		})
+	}
+}
+
+func TestStaleFetch(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+
+	tests := []struct {
+		name         string
+		lastFetch    time.Time
+		neverFetched bool
+		upstream     string
+		after        time.Duration
+		want         string
+	}{
+		{
+			name:         "never fetched",
+			neverFetched: true,
+			upstream:     "origin/main",
+			after:        time.Hour,
+			want:         "[style:clear][style:stale][symbol:stale]" + resetStyles,
+		},
+		{
+			name:         "never fetched, no upstream",
+			neverFetched: true,
+			after:        time.Hour,
+			want:         resetStyles,
+		},
+		{
+			name:     "not loaded",
+			upstream: "origin/main",
+			after:    time.Hour,
+			want:     resetStyles,
+		},
+		{
+			name:      "no upstream",
+			lastFetch: now.Add(-48 * time.Hour),
+			after:     time.Hour,
+			want:      resetStyles,
+		},
+		{
+			name:      "recent",
+			lastFetch: now.Add(-30 * time.Minute),
+			upstream:  "origin/main",
+			after:     time.Hour,
+			want:      resetStyles,
+		},
+		{
+			name:      "stale",
+			lastFetch: now.Add(-50 * time.Hour),
+			upstream:  "origin/main",
+			after:     time.Hour,
+			want:      "[style:clear][style:stale][symbol:stale]2d" + resetStyles,
+		},
+		{
+			name:      "always",
+			lastFetch: now.Add(-5 * time.Minute),
+			upstream:  "origin/main",
+			want:      "[style:clear][style:stale][symbol:stale]5m" + resetStyles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles:  styles{Clear: "[style:clear]", StaleFetch: "[style:stale]"},
+					Symbols: symbols{StaleFetch: "[symbol:stale]"},
+					Layout:  layout{"stale-fetch"},
+					Options: options{StaleFetchAfter: tt.after},
+				},
+				Info: &repo.Info{LastFetch: tt.lastFetch, NeverFetched: tt.neverFetched},
+				st:   &gitstatus.Status{Porcelain: gitstatus.Porcelain{RemoteBranch: tt.upstream}},
+			}
+
+			compareStrings(t, tt.want, f.format())
+		})
+	}
+}
+
+func TestBaseDivergence(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    repo.Divergence
+		options options
+		want    string
+	}{
+		{
+			name: "no base",
+			want: resetStyles,
+		},
+		{
+			name: "up to date",
+			base: repo.Divergence{Ref: "origin/main"},
+			want: resetStyles,
+		},
+		{
+			name: "ahead",
+			base: repo.Divergence{Ref: "origin/main", Ahead: 2},
+			want: "[style:clear][style:base][symbol:base]origin/main [symbol:ahead]2" + resetStyles,
+		},
+		{
+			name:    "diverged",
+			base:    repo.Divergence{Ref: "main", Ahead: 2, Behind: 4},
+			options: options{SwapDivergence: true, DivergenceSpace: true},
+			want:    "[style:clear][style:base][symbol:base]main [symbol:ahead]2 [symbol:behind]4" + resetStyles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles: styles{Clear: "[style:clear]", BaseDivergence: "[style:base]"},
+					Symbols: symbols{
+						Base:   "[symbol:base]",
+						Ahead:  "[symbol:ahead]",
+						Behind: "[symbol:behind]",
+					},
+					Layout:  layout{"base-divergence"},
+					Options: tt.options,
+				},
+				Info: &repo.Info{Base: tt.base},
+				st:   &gitstatus.Status{},
+			}
+
+			compareStrings(t, tt.want, f.format())
+		})
+	}
+}
+
+func TestPushDivergence(t *testing.T) {
+	tests := []struct {
+		name string
+		push repo.Divergence
+		want string
+	}{
+		{
+			name: "no push branch",
+			want: resetStyles,
+		},
+		{
+			name: "up to date",
+			push: repo.Divergence{Ref: "origin/feat"},
+			want: resetStyles,
+		},
+		{
+			name: "diverged",
+			push: repo.Divergence{Ref: "origin/feat", Ahead: 1, Behind: 3},
+			want: "[style:clear][style:push][symbol:push]origin/feat [symbol:behind]3[symbol:ahead]1" + resetStyles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles: styles{Clear: "[style:clear]", PushDivergence: "[style:push]"},
+					Symbols: symbols{
+						Push:   "[symbol:push]",
+						Ahead:  "[symbol:ahead]",
+						Behind: "[symbol:behind]",
+					},
+					Layout: layout{"push-divergence"},
+				},
+				Info: &repo.Info{Push: tt.push},
+				st:   &gitstatus.Status{},
+			}
+
+			compareStrings(t, tt.want, f.format())
+		})
+	}
+}
+
+func TestUpstream(t *testing.T) {
+	tests := []struct {
+		name     string
+		st       *gitstatus.Status
+		gone     bool
+		noUpstrm string
+		want     string
+	}{
+		{
+			name: "upstream",
+			st:   &gitstatus.Status{Porcelain: gitstatus.Porcelain{RemoteBranch: "origin/feat"}},
+			want: "[style:clear][style:remote]origin/feat" + resetStyles,
+		},
+		{
+			name: "gone",
+			st:   &gitstatus.Status{Porcelain: gitstatus.Porcelain{RemoteBranch: "origin/feat"}},
+			gone: true,
+			want: "[style:clear][style:remote]origin/feat [style:clear][style:gone][symbol:gone]" + resetStyles,
+		},
+		{
+			name: "no upstream without symbol",
+			st:   &gitstatus.Status{},
+			want: resetStyles,
+		},
+		{
+			name:     "no upstream",
+			st:       &gitstatus.Status{},
+			noUpstrm: "[symbol:none]",
+			want:     "[style:clear][style:none][symbol:none]" + resetStyles,
+		},
+		{
+			name:     "detached",
+			st:       &gitstatus.Status{Porcelain: gitstatus.Porcelain{IsDetached: true}},
+			noUpstrm: "[symbol:none]",
+			want:     resetStyles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles: styles{
+						Clear:        "[style:clear]",
+						Remote:       "[style:remote]",
+						UpstreamGone: "[style:gone]",
+						NoUpstream:   "[style:none]",
+					},
+					Symbols: symbols{UpstreamGone: "[symbol:gone]", NoUpstream: tt.noUpstrm},
+					Layout:  layout{"remote-branch"},
+				},
+				Info: &repo.Info{UpstreamGone: tt.gone},
+				st:   tt.st,
+			}
+
+			compareStrings(t, tt.want, f.format())
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	segs := segments{
+		Enabled:       true,
+		Separator:     ">",
+		ThinSeparator: "|",
+		Style:         "#[fg=black,bg=white]",
+		Styles: map[string]string{
+			"branch":        "#[fg=black,bg=blue]",
+			"remote-branch": "#[fg=black,bg=cyan]",
+			"divergence":    "#[fg=black,bg=cyan]",
+		},
+	}
+	tests := []struct {
+		name   string
+		layout layout
+		st     *gitstatus.Status
+		want   string
+	}{
+		{
+			name:   "different backgrounds",
+			layout: layout{"branch", " - ", "flags"},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "main", NumModified: 2},
+			},
+			want: "#[default]#[fg=black,bg=blue] [symbol:branch]main " +
+				"#[default,fg=blue,bg=white]>" +
+				"#[default]#[fg=black,bg=white] [symbol:mod]2 " +
+				"#[default,fg=white]>" + resetStyles,
+		},
+		{
+			name:   "same backgrounds",
+			layout: layout{"remote-branch", "divergence"},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{RemoteBranch: "origin/main", AheadCount: 1},
+			},
+			want: "#[default]#[fg=black,bg=cyan] origin/main " +
+				"#[default]#[fg=black,bg=cyan]|" +
+				"#[default]#[fg=black,bg=cyan] ↑·1 " +
+				"#[default,fg=cyan]>" + resetStyles,
+		},
+		{
+			name:   "empty components",
+			layout: layout{"branch", "divergence", "flags"},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "main"},
+			},
+			want: "#[default]#[fg=black,bg=blue] [symbol:branch]main " +
+				"#[default,fg=blue]>" + resetStyles,
+		},
+		{
+			name:   "nothing",
+			layout: layout{"divergence"},
+			st:     &gitstatus.Status{},
+			want:   resetStyles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{
+					Styles: styles{
+						Clear:    "[style:clear]",
+						Branch:   "[style:branch]",
+						Modified: "[style:mod]",
+					},
+					Symbols: symbols{
+						Branch:   "[symbol:branch]",
+						Modified: "[symbol:mod]",
+						Ahead:    "↑·",
+					},
+					Layout:   tt.layout,
+					Segments: segs,
+				},
+				st: tt.st,
+			}
+
+			compareStrings(t, tt.want, f.format())
+			if f.Styles.Clear != "[style:clear]" {
+				t.Errorf("styles not restored after formatting segments")
+			}
+		})
+	}
+}
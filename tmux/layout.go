@@ -0,0 +1,110 @@
+package tmux
+
+import (
+	"fmt"
+
+	"github.com/arl/gitstatus"
+	"gopkg.in/yaml.v3"
+)
+
+// A layout is a list of layout items. An item is either a string, which is a
+// layout keyword or a literal string, or a *conditional.
+type layout []layoutItem
+
+// A layoutItem is either a string or a *conditional.
+type layoutItem any
+
+func (l *layout) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.SequenceNode {
+		return valueError(value, "'layout': expected a list")
+	}
+
+	items := make(layout, 0, len(value.Content))
+	for _, node := range value.Content {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			s := ""
+			if err := node.Decode(&s); err != nil {
+				return err
+			}
+			items = append(items, s)
+		case yaml.MappingNode:
+			cond := &conditional{}
+			if err := node.Decode(cond); err != nil {
+				return err
+			}
+			if cond.If == "" {
+				return valueError(node, "'layout': conditional block without 'if'")
+			}
+			items = append(items, cond)
+		default:
+			return valueError(node, "'layout': unexpected item, expected a string or a conditional block")
+		}
+	}
+
+	*l = items
+	return nil
+}
+
+// valueError returns an error about the value of node. It's a *yaml.TypeError
+// so that decoding goes on, and reports all such errors at once.
+func valueError(node *yaml.Node, format string, args ...any) error {
+	msg := fmt.Sprintf("line %d: ", node.Line) + fmt.Sprintf(format, args...)
+	return &yaml.TypeError{Errors: []string{msg}}
+}
+
+// A conditional is a layout block whose items depend on the repository state.
+// Then items are shown if the condition is true, Else items otherwise.
+type conditional struct {
+	If   condition `yaml:"if"`
+	Then layout    `yaml:"then,flow"`
+	Else layout    `yaml:"else,flow"`
+}
+
+// A condition names a predicate on the repository state.
+type condition string
+
+const (
+	condClean       condition = "clean"
+	condDirty       condition = "dirty"
+	condDetached    condition = "detached"
+	condRebasing    condition = "rebasing"
+	condHasUpstream condition = "has-upstream"
+	condAhead       condition = "ahead"
+	condBehind      condition = "behind"
+)
+
+func (c *condition) UnmarshalYAML(value *yaml.Node) error {
+	s := ""
+	if err := value.Decode(&s); err != nil {
+		return fmt.Errorf("error decoding 'condition': %v", s)
+	}
+	switch condition(s) {
+	case condClean, condDirty, condDetached, condRebasing, condHasUpstream, condAhead, condBehind:
+		*c = condition(s)
+	default:
+		return valueError(value, "'condition': unexpected value %v", s)
+	}
+	return nil
+}
+
+// eval reports whether the condition holds for st.
+func (c condition) eval(st *gitstatus.Status) bool {
+	switch c {
+	case condClean:
+		return st.IsClean
+	case condDirty:
+		return !st.IsClean
+	case condDetached:
+		return st.IsDetached
+	case condRebasing:
+		return st.State == gitstatus.Rebasing
+	case condHasUpstream:
+		return st.RemoteBranch != ""
+	case condAhead:
+		return st.AheadCount != 0
+	case condBehind:
+		return st.BehindCount != 0
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package tmux
+
+import (
+	"testing"
+
+	"github.com/arl/gitstatus"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLayoutUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    layout
+		wantErr bool
+	}{
+		{
+			name: "keywords and strings",
+			yaml: `[branch, " - ", flags]`,
+			want: layout{"branch", " - ", "flags"},
+		},
+		{
+			name: "conditional",
+			yaml: `[branch, {if: dirty, then: [" - ", flags], else: [" ✔"]}]`,
+			want: layout{
+				"branch",
+				&conditional{If: condDirty, Then: layout{" - ", "flags"}, Else: layout{" ✔"}},
+			},
+		},
+		{
+			name: "nested conditionals",
+			yaml: `[{if: has-upstream, then: [{if: ahead, then: [divergence]}]}]`,
+			want: layout{
+				&conditional{If: condHasUpstream, Then: layout{
+					&conditional{If: condAhead, Then: layout{"divergence"}},
+				}},
+			},
+		},
+		{
+			name:    "unknown condition",
+			yaml:    `[{if: foo, then: [flags]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "conditional without if",
+			yaml:    `[{then: [flags], else: [branch]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "nested conditional without if",
+			yaml:    `[{if: dirty, then: [{else: [branch]}]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "nested list",
+			yaml:    `[[flags]]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got layout
+			err := yaml.Unmarshal([]byte(tt.yaml), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			f := &Formater{Config: Config{Layout: tt.want}, st: &gitstatus.Status{}}
+			want := f.format()
+			f.Layout = got
+			compareStrings(t, want, f.format())
+		})
+	}
+}
+
+func TestConditionalLayout(t *testing.T) {
+	styles := styles{
+		Clear:    "[style:clear]",
+		Modified: "[style:mod]",
+		Clean:    "[style:clean]",
+		Remote:   "[style:remote]",
+	}
+	symbols := symbols{
+		Modified: "[symbol:mod]",
+		Clean:    "[symbol:clean]",
+		Ahead:    "[symbol:ahead]",
+		Behind:   "[symbol:behind]",
+	}
+
+	tests := []struct {
+		name   string
+		layout layout
+		st     *gitstatus.Status
+		want   string
+	}{
+		{
+			name: "dirty, then branch",
+			layout: layout{
+				&conditional{If: condDirty, Then: layout{" - ", "flags"}, Else: layout{" ok"}},
+			},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{NumModified: 2},
+			},
+			want: "[style:clear] - " + "[style:clear][style:mod][symbol:mod]2" + resetStyles,
+		},
+		{
+			name: "dirty, else branch",
+			layout: layout{
+				&conditional{If: condDirty, Then: layout{" - ", "flags"}, Else: layout{" ok"}},
+			},
+			st:   &gitstatus.Status{IsClean: true},
+			want: "[style:clear] ok" + resetStyles,
+		},
+		{
+			name: "clean without else",
+			layout: layout{
+				"flags",
+				&conditional{If: condClean, Then: layout{"!"}},
+			},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{NumModified: 1},
+			},
+			want: "[style:clear][style:mod][symbol:mod]1" + resetStyles,
+		},
+		{
+			name: "keywords are joined across blocks",
+			layout: layout{
+				"remote-branch",
+				&conditional{If: condHasUpstream, Then: layout{"divergence"}},
+			},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{RemoteBranch: "origin/main", BehindCount: 3},
+			},
+			want: "[style:clear][style:remote]origin/main [style:clear][symbol:behind]3" + resetStyles,
+		},
+		{
+			name: "ahead",
+			layout: layout{
+				&conditional{If: condAhead, Then: layout{"A"}, Else: layout{"-"}},
+				&conditional{If: condBehind, Then: layout{"B"}, Else: layout{"-"}},
+			},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{AheadCount: 1},
+			},
+			want: "[style:clear]A[style:clear]-" + resetStyles,
+		},
+		{
+			name: "detached and rebasing",
+			layout: layout{
+				&conditional{If: condDetached, Then: layout{"D"}},
+				&conditional{If: condRebasing, Then: layout{"R"}},
+			},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{IsDetached: true},
+				State:     gitstatus.Rebasing,
+			},
+			want: "[style:clear]D[style:clear]R" + resetStyles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{Styles: styles, Symbols: symbols, Layout: tt.layout},
+				st:     tt.st,
+			}
+
+			compareStrings(t, tt.want, f.format())
+		})
+	}
+}
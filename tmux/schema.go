@@ -0,0 +1,49 @@
+package tmux
+
+// The JSONSchema methods describe the configuration types whose JSON Schema
+// can't be derived from their Go type alone. ref returns a reference to the
+// JSON Schema of the type of its argument.
+
+func (direction) JSONSchema(ref func(any) map[string]any) map[string]any {
+	return map[string]any{
+		"type": "string",
+		"enum": []direction{dirLeft, dirRight, dirCenter},
+	}
+}
+
+func (repoPath) JSONSchema(ref func(any) map[string]any) map[string]any {
+	return map[string]any{
+		"type": "string",
+		"enum": []repoPath{repoPathName, repoPathHome, repoPathAbsolute},
+	}
+}
+
+func (condition) JSONSchema(ref func(any) map[string]any) map[string]any {
+	return map[string]any{
+		"type": "string",
+		"enum": []condition{condClean, condDirty, condDetached, condRebasing, condHasUpstream, condAhead, condBehind},
+	}
+}
+
+func (layout) JSONSchema(ref func(any) map[string]any) map[string]any {
+	return map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			// Any string is allowed, keywords are listed for completion.
+			"anyOf": []any{
+				map[string]any{"type": "string", "enum": keywordNames()},
+				map[string]any{"type": "string"},
+				ref(conditional{}),
+			},
+		},
+	}
+}
+
+// keywordNames returns the names of the layout keywords.
+func keywordNames() []string {
+	names := make([]string, len(keywords))
+	for i, kw := range keywords {
+		names[i] = kw.name
+	}
+	return names
+}
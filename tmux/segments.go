@@ -0,0 +1,93 @@
+package tmux
+
+import (
+	"fmt"
+	"strings"
+)
+
+// segments configures the powerline-style output, in which layout components
+// are shown as segments with their own colors, separated by glyphs.
+type segments struct {
+	// Enabled shows the layout components as segments.
+	Enabled bool
+	// Separator is shown between segments with different backgrounds, in the
+	// background color of the left segment over the one of the right segment.
+	Separator string
+	// ThinSeparator is shown between segments with the same background.
+	ThinSeparator string `yaml:"thin_separator"`
+	// Style is the style of the segments of keywords not in Styles.
+	Style string
+	// Styles maps layout keywords to the style of their segment.
+	Styles map[string]string
+}
+
+// A segment is a layout component shown powerline-style.
+type segment struct {
+	style string
+	bg    Color
+	text  string
+}
+
+// segmentStyle returns the style of the segment of keyword kw.
+func (s *segments) segmentStyle(kw string) string {
+	if style, ok := s.Styles[kw]; ok {
+		return style
+	}
+	return s.Style
+}
+
+// formatSegments formats the layout as a sequence of segments, one per
+// non-empty keyword. Segments are styled with their segment style rather than
+// with Styles, and strings of the layout are ignored, since separators
+// already delimit segments.
+func (f *Formater) formatSegments() string {
+	// Components are formatted without their styles.
+	unstyled := *f
+	unstyled.Styles = styles{}
+
+	var segs []segment
+	f.walkLayout(f.Layout, func(item string) {
+		comps, ok := unstyled.components(item)
+		if !ok {
+			return
+		}
+
+		var parts []string
+		for _, c := range comps {
+			if c != "" {
+				parts = append(parts, c)
+			}
+		}
+		if len(parts) == 0 {
+			return
+		}
+
+		style := f.Segments.segmentStyle(item)
+		segs = append(segs, segment{
+			style: style,
+			bg:    parseStyle(style).Bg,
+			text:  strings.Join(parts, " "),
+		})
+	})
+
+	sb := strings.Builder{}
+	for i, seg := range segs {
+		if i > 0 {
+			// The separator transitions from the background of the previous
+			// segment to the background of this one.
+			prev := segs[i-1]
+			if prev.bg == seg.bg {
+				fmt.Fprintf(&sb, "#[default]%s%s", seg.style, f.Segments.ThinSeparator)
+			} else {
+				fmt.Fprintf(&sb, "#[default,fg=%s,bg=%s]%s", prev.bg, seg.bg, f.Segments.Separator)
+			}
+		}
+		fmt.Fprintf(&sb, "#[default]%s %s ", seg.style, seg.text)
+	}
+	if len(segs) != 0 {
+		fmt.Fprintf(&sb, "#[default,fg=%s]%s", segs[len(segs)-1].bg, f.Segments.Separator)
+	}
+
+	sb.WriteString(resetStyles)
+	return sb.String()
+}
@@ -0,0 +1,308 @@
+package tmux
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// styleDirectives splits s, a sequence of tmux style blocks such as
+// "#[fg=red,bold]#[bg=colour235]", into its directives, which it validates.
+func styleDirectives(s string) ([]string, error) {
+	var dirs []string
+	for rest := s; rest != ""; {
+		if !strings.HasPrefix(rest, "#[") {
+			return nil, fmt.Errorf("expected '#[' at %q", rest)
+		}
+		end := strings.IndexByte(rest, ']')
+		if end == -1 {
+			return nil, fmt.Errorf("missing ']' in %q", rest)
+		}
+
+		for _, d := range splitBlock(rest[2:end]) {
+			if err := (&Style{}).apply(d); err != nil {
+				return nil, err
+			}
+			dirs = append(dirs, d)
+		}
+		rest = rest[end+1:]
+	}
+	return dirs, nil
+}
+
+// splitBlock splits the content of a style block into its directives, which
+// are separated by commas or spaces.
+func splitBlock(block string) []string {
+	return strings.FieldsFunc(block, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// A Span is a piece of text and the style it's shown with.
+type Span struct {
+	Style Style
+	Text  string
+}
+
+// Spans splits s, a tmux format string such as the output of a Formater, into
+// spans of text, each with the style resulting from the style blocks before
+// it. Escaped hashes (##) are unescaped, and invalid style directives are
+// ignored.
+func Spans(s string) []Span {
+	var (
+		spans []Span
+		style Style
+		text  strings.Builder
+	)
+	flush := func() {
+		if text.Len() == 0 {
+			return
+		}
+		// Merge runs of text that end up with the same style.
+		if n := len(spans); n != 0 && spans[n-1].Style == style {
+			spans[n-1].Text += text.String()
+		} else {
+			spans = append(spans, Span{Style: style, Text: text.String()})
+		}
+		text.Reset()
+	}
+
+	for rest := s; rest != ""; {
+		start := strings.IndexByte(rest, '#')
+		if start == -1 {
+			text.WriteString(rest)
+			break
+		}
+		text.WriteString(rest[:start])
+		rest = rest[start:]
+
+		end := strings.IndexByte(rest, ']')
+		switch {
+		case strings.HasPrefix(rest, "##"):
+			// ## is an escaped #.
+			text.WriteByte('#')
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "#[") && end != -1:
+			flush()
+			for _, d := range splitBlock(rest[2:end]) {
+				style.apply(d)
+			}
+			rest = rest[end+1:]
+		default:
+			text.WriteByte('#')
+			rest = rest[1:]
+		}
+	}
+	flush()
+	return spans
+}
+
+// parseStyle returns the style resulting from the tmux style blocks of s,
+// ignoring invalid directives.
+func parseStyle(s string) Style {
+	var style Style
+	for rest := s; ; {
+		start := strings.Index(rest, "#[")
+		if start == -1 {
+			return style
+		}
+		end := strings.IndexByte(rest[start:], ']')
+		if end == -1 {
+			return style
+		}
+		for _, d := range splitBlock(rest[start+2 : start+end]) {
+			style.apply(d)
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+// A Style is the set of colors and attributes that tmux style directives
+// apply to the text that follows them.
+type Style struct {
+	Fg, Bg Color // Fg and Bg are the foreground and background colors.
+	Us     Color // Us is the underscore color.
+	Attrs  Attr  // Attrs are the attributes set.
+}
+
+// An Attr is a bit set of tmux style attributes.
+type Attr uint
+
+// Style attributes.
+const (
+	AttrBold Attr = 1 << iota
+	AttrDim
+	AttrUnderscore
+	AttrBlink
+	AttrReverse
+	AttrHidden
+	AttrItalics
+	AttrOverline
+	AttrStrikethrough
+	AttrDoubleUnderscore
+	AttrCurlyUnderscore
+	AttrDottedUnderscore
+	AttrDashedUnderscore
+	AttrACS
+)
+
+// attrNames maps tmux style attribute names to attributes. Each can be
+// prefixed by 'no' to turn it off.
+var attrNames = map[string]Attr{
+	"acs":               AttrACS,
+	"bright":            AttrBold,
+	"bold":              AttrBold,
+	"dim":               AttrDim,
+	"underscore":        AttrUnderscore,
+	"blink":             AttrBlink,
+	"reverse":           AttrReverse,
+	"hidden":            AttrHidden,
+	"italics":           AttrItalics,
+	"overline":          AttrOverline,
+	"strikethrough":     AttrStrikethrough,
+	"double-underscore": AttrDoubleUnderscore,
+	"curly-underscore":  AttrCurlyUnderscore,
+	"dotted-underscore": AttrDottedUnderscore,
+	"dashed-underscore": AttrDashedUnderscore,
+}
+
+// apply applies the tmux style directive d, such as "fg=red" or "nobold", to
+// the style.
+func (s *Style) apply(d string) error {
+	key, val, hasVal := strings.Cut(d, "=")
+	if !hasVal {
+		switch d {
+		case "default":
+			*s = Style{}
+			return nil
+		case "none":
+			s.Attrs = 0
+			return nil
+		case "push-default", "pop-default", "nolist", "norange", "ignore", "noignore":
+			// Only meaningful to tmux.
+			return nil
+		}
+		if attr, ok := attrNames[d]; ok {
+			s.Attrs |= attr
+			return nil
+		}
+		if attr, ok := attrNames[strings.TrimPrefix(d, "no")]; ok {
+			s.Attrs &^= attr
+			return nil
+		}
+		return fmt.Errorf("unknown style attribute %q", d)
+	}
+
+	switch key {
+	case "fg", "bg", "us", "fill":
+		c, err := ParseColor(val)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "fg":
+			s.Fg = c
+		case "bg":
+			s.Bg = c
+		case "us":
+			s.Us = c
+		}
+	case "align":
+		switch val {
+		case "left", "centre", "right", "absolute-centre":
+		default:
+			return fmt.Errorf("unknown alignment %q", val)
+		}
+	case "list":
+		switch val {
+		case "on", "focus", "left-marker", "right-marker":
+		default:
+			return fmt.Errorf("unknown list value %q", val)
+		}
+	case "range":
+		if val == "" {
+			return fmt.Errorf("empty range")
+		}
+	default:
+		return fmt.Errorf("unknown style option %q", key)
+	}
+	return nil
+}
+
+// ColorNames lists the tmux named colors, in the order of their ANSI codes.
+var ColorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// A ColorKind tells how a color is specified.
+type ColorKind int
+
+const (
+	ColorDefault ColorKind = iota // ColorDefault is the default or terminal color.
+	ColorBasic                    // ColorBasic is one of the 8 named colors (0-7), or their bright variant (8-15).
+	Color256                      // Color256 is an index in the 256 colors palette.
+	ColorRGB                      // ColorRGB is a 24-bit color.
+)
+
+// A Color is a tmux color. Its value is an index for ColorBasic and Color256,
+// and 0xRRGGBB for ColorRGB.
+type Color struct {
+	Kind  ColorKind
+	Value int
+}
+
+// ParseColor parses a tmux color: default, terminal, a name such as red or
+// brightred, colour0 to colour255 (or color0 to color255), #rrggbb, or the
+// name of an X11 color such as orange, which is an RGB color.
+func ParseColor(s string) (Color, error) {
+	switch s {
+	case "default", "terminal":
+		return Color{Kind: ColorDefault}, nil
+	}
+
+	for i, name := range ColorNames {
+		switch s {
+		case name:
+			return Color{Kind: ColorBasic, Value: i}, nil
+		case "bright" + name:
+			return Color{Kind: ColorBasic, Value: i + 8}, nil
+		}
+	}
+
+	for _, prefix := range []string{"colour", "color"} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			n, err := strconv.Atoi(rest)
+			if err != nil || n < 0 || n > 255 {
+				return Color{}, fmt.Errorf("invalid color %q, expected %s0 to %s255", s, prefix, prefix)
+			}
+			return Color{Kind: Color256, Value: n}, nil
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(s, "#"); ok {
+		n, err := strconv.ParseUint(rest, 16, 32)
+		if err != nil || len(rest) != 6 {
+			return Color{}, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+		}
+		return Color{Kind: ColorRGB, Value: int(n)}, nil
+	}
+
+	// Like tmux, X11 color names are case insensitive.
+	if rgb, ok := x11Colors[strings.ToLower(s)]; ok {
+		return Color{Kind: ColorRGB, Value: rgb}, nil
+	}
+
+	return Color{}, fmt.Errorf("unknown color %q", s)
+}
+
+// String returns the tmux name of c, which ParseColor parses back to c.
+func (c Color) String() string {
+	switch c.Kind {
+	case ColorBasic:
+		if c.Value >= len(ColorNames) {
+			return "bright" + ColorNames[c.Value-len(ColorNames)]
+		}
+		return ColorNames[c.Value]
+	case Color256:
+		return "colour" + strconv.Itoa(c.Value)
+	case ColorRGB:
+		return fmt.Sprintf("#%06x", c.Value)
+	}
+	return "default"
+}
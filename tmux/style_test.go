@@ -0,0 +1,125 @@
+package tmux
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	red := Color{Kind: ColorBasic, Value: 1}
+	tests := []struct {
+		s    string
+		want []Span
+	}{
+		{s: ""},
+		{s: "#[fg=red]", want: nil},
+		{s: "plain", want: []Span{{Text: "plain"}}},
+		{
+			s: "#[none]#[fg=red,bold]main#[none] ##1 #[fg=default]x",
+			want: []Span{
+				{Style: Style{Fg: red, Attrs: AttrBold}, Text: "main"},
+				{Style: Style{Fg: red}, Text: " #1 "},
+				{Text: "x"},
+			},
+		},
+		{
+			s: "#[fg=colour235 bg=#102030 underscore]a#[nounderscore,dim]b#[default]c",
+			want: []Span{
+				{Style: Style{Fg: Color{Kind: Color256, Value: 235}, Bg: Color{Kind: ColorRGB, Value: 0x102030}, Attrs: AttrUnderscore}, Text: "a"},
+				{Style: Style{Fg: Color{Kind: Color256, Value: 235}, Bg: Color{Kind: ColorRGB, Value: 0x102030}, Attrs: AttrDim}, Text: "b"},
+				{Text: "c"},
+			},
+		},
+		{
+			s:    "#[fg=red]a#[none]b#[fg=red]c",
+			want: []Span{{Style: Style{Fg: red}, Text: "abc"}},
+		},
+		{
+			s:    "###[fg=red]# ##[x]",
+			want: []Span{{Text: "#"}, {Style: Style{Fg: red}, Text: "# #[x]"}},
+		},
+		{
+			s:    "#[fg=nope,bold]a#[unterminated",
+			want: []Span{{Style: Style{Attrs: AttrBold}, Text: "a#[unterminated"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := Spans(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Spans(%q) =\n%+v\nwant\n%+v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Color
+		wantErr bool
+	}{
+		{s: "default", want: Color{Kind: ColorDefault}},
+		{s: "terminal", want: Color{Kind: ColorDefault}},
+		{s: "black", want: Color{Kind: ColorBasic, Value: 0}},
+		{s: "white", want: Color{Kind: ColorBasic, Value: 7}},
+		{s: "brightred", want: Color{Kind: ColorBasic, Value: 9}},
+		{s: "colour0", want: Color{Kind: Color256, Value: 0}},
+		{s: "color255", want: Color{Kind: Color256, Value: 255}},
+		{s: "#ff8000", want: Color{Kind: ColorRGB, Value: 0xff8000}},
+		{s: "colour256", wantErr: true},
+		{s: "colour", wantErr: true},
+		{s: "#ff80", wantErr: true},
+		{s: "#gg0000", wantErr: true},
+		{s: "orange", want: Color{Kind: ColorRGB, Value: 0xffa500}},
+		{s: "DarkSlateGray4", want: Color{Kind: ColorRGB, Value: 0x528b8b}},
+		{s: "grey50", want: Color{Kind: ColorRGB, Value: 0x7f7f7f}},
+		{s: "nocolor", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseColor(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseColor(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseColor(%q) = %+v, want %+v", tt.s, got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			if back, err := ParseColor(got.String()); err != nil || back != got {
+				t.Errorf("ParseColor(%q) = %+v, %v, want %+v", got.String(), back, err, got)
+			}
+		})
+	}
+}
+
+func Test_styleDirectives(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{s: ""},
+		{s: "#[]"},
+		{s: "#[fg=red,bold]", want: []string{"fg=red", "bold"}},
+		{s: "#[fg=default bg=default]#[none]", want: []string{"fg=default", "bg=default", "none"}},
+		{s: "#[nobold,align=right]", want: []string{"nobold", "align=right"}},
+		{s: "#[fg=red", wantErr: true},
+		{s: "#[fg=red]x", wantErr: true},
+		{s: "#[blod]", wantErr: true},
+		{s: "#[fgcolor=red]", wantErr: true},
+		{s: "#[align=middle]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := styleDirectives(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("styleDirectives(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("styleDirectives(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
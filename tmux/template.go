@@ -0,0 +1,194 @@
+package tmux
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/arl/gitstatus"
+
+	"github.com/arl/gitmux/repo"
+)
+
+// execTemplate executes the configured template with the Git status and the
+// repository information. Along with their fields, templates can use the following functions:
+//   - truncate MAX STR: truncates STR to MAX runes, as per branch_trim and ellipsis options.
+//   - style NAME: the style string named NAME in the styles section.
+//   - symbol NAME: the symbol named NAME in the symbols section.
+//   - component KEYWORD: what layout KEYWORD shows.
+func (f *Formater) execTemplate() (string, error) {
+	tmpl, err := template.New("gitmux").Funcs(f.templateFuncs()).Parse(f.Template)
+	if err != nil {
+		return "", fmt.Errorf("can't parse template: %v", err)
+	}
+
+	var info repo.Info
+	if f.Info != nil {
+		info = *f.Info
+	}
+	info.Commit.Subject = escapeHash(info.Commit.Subject)
+	info.Commit.Author = escapeHash(info.Commit.Author)
+
+	data := struct {
+		*gitstatus.Status
+		*repo.Info
+	}{f.st, &info}
+
+	sb := strings.Builder{}
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("can't execute template: %v", err)
+	}
+	return sb.String(), nil
+}
+
+// templateFuncs returns the functions templates can call.
+func (f *Formater) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"truncate": func(max int, s string) string {
+			dir := f.Options.BranchTrim
+			if dir == "" {
+				dir = dirRight
+			}
+			return truncate(s, f.Options.Ellipsis, max, dir)
+		},
+		"style": func(name string) (string, error) {
+			return lookupField(f.Styles, "style", name)
+		},
+		"symbol": func(name string) (string, error) {
+			return lookupField(f.Symbols, "symbol", name)
+		},
+		"component": func(kw string) (string, error) {
+			comps, ok := f.components(kw)
+			if !ok {
+				return "", fmt.Errorf("unknown component %q", kw)
+			}
+			nonEmpty := comps[:0]
+			for _, c := range comps {
+				if c != "" {
+					nonEmpty = append(nonEmpty, c)
+				}
+			}
+			return strings.Join(nonEmpty, " "), nil
+		},
+	}
+}
+
+// templateFields returns the repository information used by the template: the
+// fields of repo.Info it refers to, and what the components it shows
+// require. It returns repo.All if it can't tell, for example if the template
+// uses dot as a whole or calls component with a computed keyword.
+func (c *Config) templateFields() repo.Fields {
+	tmpl, err := template.New("gitmux").Funcs((&Formater{}).templateFuncs()).Parse(c.Template)
+	if err != nil {
+		// Executing the template will report the error.
+		return repo.All
+	}
+
+	var (
+		fields repo.Fields
+		all    bool
+	)
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if id, ok := n.Args[0].(*parse.IdentifierNode); ok && id.Ident == "component" {
+				if len(n.Args) == 2 {
+					if kw, ok := n.Args[1].(*parse.StringNode); ok {
+						fields |= c.keywordFields(kw.Text)
+						return
+					}
+				}
+				all = true
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.FieldNode:
+			fields |= chainFields(n.Ident)
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				fields |= chainFields(n.Ident[1:])
+			}
+		case *parse.DotNode:
+			all = true
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+
+	if all {
+		return repo.All
+	}
+	return fields
+}
+
+// chainFields returns the repository information a chain of fields of the
+// template data, such as .Commit.Subject, refers to. Since repo.Info is
+// embedded, its fields can also be reached through .Info.
+func chainFields(ident []string) repo.Fields {
+	if ident[0] == "Info" {
+		if len(ident) == 1 {
+			return repo.All
+		}
+		ident = ident[1:]
+	}
+	return repo.InfoFields[ident[0]]
+}
+
+// lookupField returns the value of the string field of v (a struct) whose
+// configuration key is name.
+func lookupField(v any, kind, name string) (string, error) {
+	rv := reflect.ValueOf(v)
+	for i := 0; i < rv.NumField(); i++ {
+		if yamlKey(rv.Type().Field(i)) == name {
+			return rv.Field(i).String(), nil
+		}
+	}
+	return "", fmt.Errorf("unknown %s %q", kind, name)
+}
+
+// yamlKey returns the key of a struct field in the configuration file.
+func yamlKey(field reflect.StructField) string {
+	if key, _, _ := strings.Cut(field.Tag.Get("yaml"), ","); key != "" {
+		return key
+	}
+	return strings.ToLower(field.Name)
+}
@@ -0,0 +1,137 @@
+package tmux
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arl/gitstatus"
+
+	"github.com/arl/gitmux/repo"
+)
+
+func TestTemplate(t *testing.T) {
+	styles := styles{
+		Clear:    "[style:clear]",
+		Branch:   "[style:branch]",
+		Modified: "[style:mod]",
+	}
+	symbols := symbols{
+		Branch:     "[symbol:branch]",
+		HashPrefix: "[symbol:hash]",
+		Modified:   "[symbol:mod]",
+
+		SubmoduleDirty: "[symbol:subdirty]",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		options  options
+		st       *gitstatus.Status
+		info     *repo.Info
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "status fields",
+			template: `{{.LocalBranch}} {{.NumModified}}`,
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "main", NumModified: 2},
+			},
+			want: "main 2",
+		},
+		{
+			name:     "style and symbol",
+			template: `{{style "branch"}}{{symbol "branch"}}{{.LocalBranch}}{{if .IsDetached}}{{symbol "hashprefix"}}{{end}}`,
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "main"},
+			},
+			want: "[style:branch][symbol:branch]main",
+		},
+		{
+			name:     "symbol with snake case key",
+			template: `{{symbol "submodule_dirty"}}`,
+			st:       &gitstatus.Status{},
+			want:     "[symbol:subdirty]",
+		},
+		{
+			name:     "conditional on count",
+			template: `{{if gt .Insertions 100}}+{{.Insertions}}{{end}}|{{if gt .Deletions 100}}-{{.Deletions}}{{end}}`,
+			st:       &gitstatus.Status{Insertions: 101, Deletions: 100},
+			want:     "+101|",
+		},
+		{
+			name:     "truncate",
+			template: `{{.LocalBranch | truncate 5}}`,
+			options:  options{Ellipsis: "…"},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "feature/foo"},
+			},
+			want: "feat…",
+		},
+		{
+			name:     "truncate left",
+			template: `{{truncate 5 .LocalBranch}}`,
+			options:  options{Ellipsis: "…", BranchTrim: dirLeft},
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{LocalBranch: "feature/foo"},
+			},
+			want: "…/foo",
+		},
+		{
+			name:     "component",
+			template: `[{{component "flags"}}]`,
+			st: &gitstatus.Status{
+				Porcelain: gitstatus.Porcelain{NumModified: 1},
+			},
+			want: "[[style:clear][style:mod][symbol:mod]1]",
+		},
+		{
+			name:     "commit hashes",
+			template: `{{.Commit.Subject}} {{.Commit.Author}}`,
+			st:       &gitstatus.Status{},
+			info:     &repo.Info{Commit: repo.CommitInfo{Subject: "Fix #12", Author: "#[fg=red]"}},
+			want:     "Fix ##12 ##[fg=red]",
+		},
+		{
+			name:     "unknown style",
+			template: `{{style "foo"}}`,
+			st:       &gitstatus.Status{},
+			wantErr:  `unknown style "foo"`,
+		},
+		{
+			name:     "unknown component",
+			template: `{{component "foo"}}`,
+			st:       &gitstatus.Status{},
+			wantErr:  `unknown component "foo"`,
+		},
+		{
+			name:     "parse error",
+			template: `{{if}}`,
+			st:       &gitstatus.Status{},
+			wantErr:  "can't parse template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Formater{
+				Config: Config{Styles: styles, Symbols: symbols, Options: tt.options, Template: tt.template},
+				Info:   tt.info,
+			}
+
+			sb := strings.Builder{}
+			err := f.Format(&sb, tt.st)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Format error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Format error: %s", err)
+			}
+
+			compareStrings(t, "[style:clear]"+tt.want+resetStyles+"[style:clear]", sb.String())
+		})
+	}
+}
@@ -0,0 +1,126 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/arl/gitmux/repo"
+)
+
+// canWatch reports whether repositories can be watched for changes.
+const canWatch = true
+
+// inotify events signaling a change in a watched directory.
+const watchMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR
+
+// watchRepo watches, with inotify, the Git directory and the working tree of
+// the repository whose directories are dirs, as well as the refs of the common
+// Git directory in a linked worktree. changed gets called each time something
+// changes in one of them, until stop is called. ctx only bounds setting up the
+// watch.
+func watchRepo(ctx context.Context, dirs repo.Dirs, changed func()) (stop func(), err error) {
+	gitdir, common, top := dirs.GitDir, dirs.CommonDir, dirs.Toplevel
+
+	ignored, err := ignoredDirs(ctx, top)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	// Since the file descriptor is non-blocking, reads from f go through the
+	// runtime poller, and closing f unblocks them.
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	add := func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+
+		switch {
+		case path == filepath.Join(gitdir, "objects"):
+			// Objects only change along with refs or index.
+			return filepath.SkipDir
+		case path == gitdir || strings.HasPrefix(path, gitdir+string(filepath.Separator)):
+			// Watch everything else in the Git directory.
+		case d.Name() == ".git" || ignored[path]:
+			return filepath.SkipDir
+		}
+
+		if _, err := syscall.InotifyAddWatch(fd, path, watchMask); err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		return nil
+	}
+
+	for _, root := range []string{gitdir, top} {
+		if err := filepath.WalkDir(root, add); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if common != gitdir {
+		// In a linked worktree, refs are shared by all worktrees and live in
+		// the common directory, packed-refs at its root.
+		if _, err := syscall.InotifyAddWatch(fd, common, watchMask); err != nil {
+			f.Close()
+			return nil, os.NewSyscallError("inotify_add_watch", err)
+		}
+		addRefs := func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			if _, err := syscall.InotifyAddWatch(fd, path, watchMask); err != nil {
+				return os.NewSyscallError("inotify_add_watch", err)
+			}
+			return nil
+		}
+		if err := filepath.WalkDir(filepath.Join(common, "refs"), addRefs); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, err := f.Read(buf); err != nil {
+				return
+			}
+			changed()
+		}
+	}()
+
+	return func() { f.Close() }, nil
+}
+
+// ignoredDirs returns the set of directories of the working tree in top that
+// are ignored by Git.
+func ignoredDirs(ctx context.Context, top string) (map[string]bool, error) {
+	out, err := repo.Git(ctx, top, "ls-files", "-z", "--others", "--ignored", "--exclude-standard", "--directory")
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]bool)
+	for _, p := range bytes.Split(out, []byte{0}) {
+		if bytes.HasSuffix(p, []byte("/")) {
+			dirs[filepath.Join(top, string(p))] = true
+		}
+	}
+	return dirs, nil
+}
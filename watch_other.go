@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/arl/gitmux/repo"
+)
+
+// canWatch reports whether repositories can be watched for changes. Other
+// platforms than linux rely on the cache entries expiring.
+const canWatch = false
+
+func watchRepo(ctx context.Context, dirs repo.Dirs, changed func()) (stop func(), err error) {
+	return nil, errors.New("watching repositories is not supported on this platform")
+}
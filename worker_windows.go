@@ -0,0 +1,7 @@
+package main
+
+import "os/exec"
+
+// killGroup does nothing on windows, only cmd gets killed when its context is
+// done.
+func killGroup(cmd *exec.Cmd) {}